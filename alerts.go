@@ -0,0 +1,103 @@
+package darksky
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+/*
+Severity is how urgent Darksky considers an alert
+*/
+type Severity string
+
+const (
+	// SeverityAdvisory means an individual should be aware of potentially severe
+	// weather
+	SeverityAdvisory Severity = "advisory"
+	// SeverityWatch means an individual should prepare for potentially severe
+	// weather
+	SeverityWatch Severity = "watch"
+	// SeverityWarning means an individual should take immediate action to
+	// protect themselves and others from potentially severe weather
+	SeverityWarning Severity = "warning"
+)
+
+func (s Severity) rank() int {
+	switch s {
+	case SeverityAdvisory:
+		return 1
+	case SeverityWatch:
+		return 2
+	case SeverityWarning:
+		return 3
+	}
+	return 0
+}
+
+/*
+eventSeverity reads the severity from an event named like "Winter Storm
+Warning", as alerts from other providers are, returning "" when the name does
+not say
+*/
+func eventSeverity(event string) Severity {
+	switch {
+	case strings.HasSuffix(event, "Warning"):
+		return SeverityWarning
+	case strings.HasSuffix(event, "Watch"):
+		return SeverityWatch
+	case strings.HasSuffix(event, "Advisory"), strings.HasSuffix(event, "Statement"):
+		return SeverityAdvisory
+	}
+	return ""
+}
+
+/*
+AtLeast reports whether s is as severe as min. Unknown severities are only at
+least as severe as other unknown severities.
+*/
+func (s Severity) AtLeast(min Severity) bool {
+	return s.rank() >= min.rank()
+}
+
+/*
+Alert is a severe weather alert issued for the requested location
+*/
+type Alert struct {
+	Title       string    `json:"title"`
+	Regions     []string  `json:"regions,omitempty"`
+	Severity    Severity  `json:"severity"`
+	Time        UnixTime  `json:"time"`
+	Expires     *UnixTime `json:"expires,omitempty"`
+	Description string    `json:"description"`
+	URI         string    `json:"uri"`
+	// Extra holds unknown fields, see Response.Extra
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+/*
+Active reports whether the alert is in effect at t
+*/
+func (a Alert) Active(t time.Time) bool {
+	if t.Before(time.Time(a.Time)) {
+		return false
+	}
+
+	return a.Expires == nil || t.Before(time.Time(*a.Expires))
+}
+
+/*
+ActiveAlerts returns the alerts in effect at t that are at least as severe as
+min, in the order Darksky sent them
+*/
+func (r Response) ActiveAlerts(t time.Time, min Severity) []Alert {
+	var ret []Alert
+
+	for _, a := range r.Alerts {
+		if a.Active(t) && a.Severity.AtLeast(min) {
+			ret = append(ret, a)
+		}
+	}
+
+	return ret
+}
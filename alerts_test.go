@@ -0,0 +1,74 @@
+package darksky
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+var (
+	alertsJSON = []byte(`
+	{"latitude":37.8267,"longitude":-122.4233,"timezone":"America/Los_Angeles","alerts":[{"title":"Wind Advisory","regions":["San Francisco","North Bay Interior Valleys"],"severity":"advisory","time":1551880800,"expires":1551924000,"description":"...WIND ADVISORY REMAINS IN EFFECT UNTIL 6 PM PST THIS EVENING...\n","uri":"https://alerts.weather.gov/cap/wwacapget.php?x=CA125CEB8B2F2C.WindAdvisory.125CEB9A0C40CA.MTRNPWMTR.1a3b0f8d1c7c3e8a1e4b0b4e8ac1e7f8"},{"title":"Flood Watch","regions":["San Francisco","Santa Cruz Mountains"],"severity":"watch","time":1551866400,"expires":1551945600,"description":"...FLOOD WATCH IN EFFECT THROUGH THURSDAY MORNING...\n","uri":"https://alerts.weather.gov/cap/wwacapget.php?x=CA125CEB8B1E40.FloodWatch.125CEB9B3C00CA.MTRFFAMTR.5a0d39a1e1a0ec3c2e9a4a1e4f5c5b21"},{"title":"High Surf Warning","regions":["San Francisco"],"severity":"warning","time":1551945600,"expires":1551981600,"description":"...HIGH SURF WARNING IN EFFECT FROM THURSDAY MORNING...\n","uri":"https://alerts.weather.gov/cap/wwacapget.php?x=CA125CEB8C0A60.HighSurfWarning.125CEB9C1B00CA.MTRCFWMTR.b9f07bc3f11d0d9a5c1e0e2df6a3b0c4"}],"flags":{"sources":["darksky"],"units":"us"},"offset":-8}
+	`)
+)
+
+func TestAlertsUnmarshal(t *testing.T) {
+	var res Response
+
+	if err := json.Unmarshal(alertsJSON, &res); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(res.Alerts) != 3 {
+		t.Fatalf("expected 3 alerts got %d", len(res.Alerts))
+	}
+
+	a := res.Alerts[0]
+
+	if a.Title != "Wind Advisory" || a.Severity != SeverityAdvisory || len(a.Regions) != 2 {
+		t.Errorf("first alert not decoded properly: %+v", a)
+	}
+
+	if a.Expires == nil || !a.Expires.Equal(UnixTime(time.Unix(1551924000, 0))) {
+		t.Errorf("expected first alert to expire at %v got %v", time.Unix(1551924000, 0), a.Expires)
+	}
+}
+
+func TestActiveAlerts(t *testing.T) {
+	var res Response
+
+	if err := json.Unmarshal(alertsJSON, &res); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		at   int64
+		min  Severity
+		want []string
+	}{
+		{1551886726, SeverityAdvisory, []string{"Wind Advisory", "Flood Watch"}},
+		{1551886726, SeverityWatch, []string{"Flood Watch"}},
+		{1551886726, SeverityWarning, nil},
+		{1551950000, SeverityAdvisory, []string{"High Surf Warning"}},
+		{1551981600, SeverityAdvisory, nil},
+	}
+
+	for _, test := range tests {
+		var got []string
+		for _, a := range res.ActiveAlerts(time.Unix(test.at, 0), test.min) {
+			got = append(got, a.Title)
+		}
+
+		if len(got) != len(test.want) {
+			t.Errorf("at %d with %s expected %v got %v", test.at, test.min, test.want, got)
+			continue
+		}
+
+		for i := range got {
+			if got[i] != test.want[i] {
+				t.Errorf("at %d with %s expected %v got %v", test.at, test.min, test.want, got)
+				break
+			}
+		}
+	}
+}
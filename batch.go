@@ -0,0 +1,121 @@
+package darksky
+
+import (
+	"context"
+	"sync"
+)
+
+const defaultConcurrency = 8
+
+/*
+Location is a point to fetch a forecast for
+*/
+type Location struct {
+	Latitude  float32
+	Longitude float32
+}
+
+/*
+Result is the outcome of fetching one location of a batch
+*/
+type Result struct {
+	// Index is the position of Location in the batch
+	Index    int
+	Location Location
+	Response Response
+	Meta     ResponseMeta
+	Err      error
+}
+
+/*
+WithConcurrency bounds the number of requests GetMany and Stream have in
+flight at once
+*/
+func WithConcurrency(n int) Option {
+	return func(s *Service) {
+		s.Concurrency = n
+	}
+}
+
+func (s *Service) concurrency() int {
+	if s.Concurrency > 0 {
+		return s.Concurrency
+	}
+	return defaultConcurrency
+}
+
+/*
+GetMany fetches every location with at most s.Concurrency requests in flight
+and returns one Result per location, in input order. A failure for one
+location does not stop the others; check each Result's Err.
+*/
+func (s *Service) GetMany(ctx context.Context, locs []Location, opts ...RequestOption) []Result {
+	ret := make([]Result, len(locs))
+	received := make([]bool, len(locs))
+
+	for res := range s.Stream(ctx, locs, opts...) {
+		ret[res.Index] = res
+		received[res.Index] = true
+	}
+
+	// results dropped by Stream because ctx ended
+	for i := range ret {
+		if !received[i] {
+			ret[i] = Result{Index: i, Location: locs[i], Err: ctx.Err()}
+		}
+	}
+
+	return ret
+}
+
+/*
+Stream fetches every location like GetMany but delivers each Result as soon
+as it is ready, closing the channel when the batch is done. Once ctx ends the
+remaining locations are not requested; the caller must keep receiving until
+the channel is closed or cancel ctx.
+*/
+func (s *Service) Stream(ctx context.Context, locs []Location, opts ...RequestOption) <-chan Result {
+	out := make(chan Result)
+	jobs := make(chan int)
+
+	workers := s.concurrency()
+	if workers > len(locs) {
+		workers = len(locs)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(workers)
+
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+
+			for i := range jobs {
+				res := Result{Index: i, Location: locs[i]}
+
+				if err := ctx.Err(); err != nil {
+					res.Err = err
+				} else {
+					res.Response, res.Meta, res.Err = s.GetWithMeta(ctx, locs[i].Latitude, locs[i].Longitude, opts...)
+				}
+
+				select {
+				case out <- res:
+				case <-ctx.Done():
+				}
+			}
+		}()
+	}
+
+	go func() {
+		for i := range locs {
+			jobs <- i
+		}
+		close(jobs)
+
+		wg.Wait()
+		close(out)
+	}()
+
+	return out
+}
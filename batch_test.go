@@ -0,0 +1,80 @@
+package darksky
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestGetMany(t *testing.T) {
+	var inFlight, maxInFlight int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+
+		for {
+			m := atomic.LoadInt32(&maxInFlight)
+			if n <= m || atomic.CompareAndSwapInt32(&maxInFlight, m, n) {
+				break
+			}
+		}
+
+		if strings.HasSuffix(r.URL.Path, ",0") {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"code":400,"error":"The given location is invalid."}`))
+			return
+		}
+		w.Write(exampleJSON)
+	}))
+	defer srv.Close()
+
+	s := NewService("key", WithConcurrency(3))
+	s.BaseURL = srv.URL
+
+	locs := make([]Location, 20)
+	for i := range locs {
+		locs[i] = Location{Latitude: float32(i), Longitude: float32(i % 5)}
+	}
+
+	results := s.GetMany(context.Background(), locs)
+
+	if len(results) != len(locs) {
+		t.Fatalf("expected %d results got %d", len(locs), len(results))
+	}
+
+	for i, res := range results {
+		if res.Index != i || res.Location != locs[i] {
+			t.Errorf("result %d out of order: %+v", i, res.Location)
+		}
+
+		if wantErr := i%5 == 0; wantErr != errors.Is(res.Err, ErrBadRequest) {
+			t.Errorf("result %d unexpected error %v", i, res.Err)
+		} else if !wantErr && res.Response.Latitude != 37.8267 {
+			t.Errorf("result %d not decoded", i)
+		}
+	}
+
+	if maxInFlight > 3 {
+		t.Errorf("expected at most 3 requests in flight got %d", maxInFlight)
+	}
+}
+
+func TestGetManyCanceled(t *testing.T) {
+	s := NewService("key")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	locs := []Location{{37.8267, -122.4233}, {40.7128, -74.006}}
+
+	for i, res := range s.GetMany(ctx, locs) {
+		if res.Index != i || res.Err != context.Canceled {
+			t.Errorf("result %d expected %v got %v", i, context.Canceled, res.Err)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package darksky
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+const (
+	defaultMaxBodySize = 8 << 20
+	maxDrainSize       = 64 << 10
+)
+
+/*
+ErrBodyTooLarge is returned when a response body exceeds the service's
+MaxBodySize
+*/
+var ErrBodyTooLarge = errors.New("darksky: response body too large")
+
+/*
+maxBodySize returns the configured body limit, or the default
+*/
+func (s *Service) maxBodySize() int64 {
+	if s.MaxBodySize > 0 {
+		return s.MaxBodySize
+	}
+	return defaultMaxBodySize
+}
+
+/*
+openBody returns a reader of res's decoded body. Requests ask for gzip
+explicitly, which turns off the transport's transparent decompression, so the
+Content-Encoding is handled here.
+*/
+func openBody(res *http.Response) (io.Reader, error) {
+	switch strings.ToLower(strings.TrimSpace(res.Header.Get("Content-Encoding"))) {
+	case "", "identity":
+		return res.Body, nil
+	case "gzip", "x-gzip":
+		return gzip.NewReader(res.Body)
+	}
+
+	return nil, errors.New("darksky: unsupported content encoding " + res.Header.Get("Content-Encoding"))
+}
+
+/*
+errorBody returns a reader of the body of res, a non-2xx response. When it
+cannot be decoded, such as an empty gzip body or an encoding this package does
+not handle, the raw body is used so the status is still reported.
+*/
+func errorBody(res *http.Response) io.Reader {
+	body, err := openBody(res)
+	if err != nil {
+		return res.Body
+	}
+	return body
+}
+
+/*
+decodeBody streams a JSON document from r into v, failing with
+ErrBodyTooLarge past max decoded bytes
+*/
+func decodeBody(r io.Reader, max int64, v interface{}) error {
+	return json.NewDecoder(&limitedReader{r: r, n: max}).Decode(v)
+}
+
+/*
+readBody reads all of r, failing with ErrBodyTooLarge past max decoded bytes
+*/
+func readBody(r io.Reader, max int64) ([]byte, error) {
+	return ioutil.ReadAll(&limitedReader{r: r, n: max})
+}
+
+/*
+limitedReader is io.LimitedReader that reports an error, rather than EOF,
+when there is more to read past the limit
+*/
+type limitedReader struct {
+	r io.Reader
+	n int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.n <= 0 {
+		// a body of exactly the limit is fine, so look for one more byte
+		var b [1]byte
+		if n, err := l.r.Read(b[:]); n == 0 {
+			return 0, err
+		}
+		return 0, ErrBodyTooLarge
+	}
+
+	if int64(len(p)) > l.n {
+		p = p[:l.n]
+	}
+
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	return n, err
+}
+
+/*
+closeBody drains a little of what is left of body, so the connection can be
+reused, and closes it
+*/
+func closeBody(body io.ReadCloser) {
+	io.Copy(ioutil.Discard, io.LimitReader(body, maxDrainSize))
+	body.Close()
+}
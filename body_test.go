@@ -0,0 +1,249 @@
+package darksky
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type closeRecorder struct {
+	io.ReadCloser
+	closed bool
+}
+
+func (c *closeRecorder) Close() error {
+	c.closed = true
+	return c.ReadCloser.Close()
+}
+
+type recordingTransport struct {
+	bodies []*closeRecorder
+}
+
+func (rt *recordingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	res, err := http.DefaultTransport.RoundTrip(r)
+	if err != nil {
+		return nil, err
+	}
+
+	body := &closeRecorder{ReadCloser: res.Body}
+	rt.bodies = append(rt.bodies, body)
+	res.Body = body
+	return res, nil
+}
+
+func TestBodyGzip(t *testing.T) {
+	var acceptEncoding string
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		acceptEncoding = r.Header.Get("Accept-Encoding")
+
+		w.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(w)
+		gz.Write(exampleJSON)
+		gz.Close()
+	}))
+	defer srv.Close()
+
+	s := NewService("key")
+	s.BaseURL = srv.URL
+
+	res, err := s.Get(37.8267, -122.4233)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if acceptEncoding != "gzip" {
+		t.Errorf("expected gzip to be requested got %q", acceptEncoding)
+	}
+
+	if res.Latitude != 37.8267 {
+		t.Errorf("latitude is not correct")
+	}
+}
+
+func TestBodyTooLarge(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(exampleJSON)
+	}))
+	defer srv.Close()
+
+	s := NewService("key")
+	s.BaseURL = srv.URL
+	s.MaxBodySize = 1024
+
+	if _, err := s.Get(37.8267, -122.4233); !errors.Is(err, ErrBodyTooLarge) {
+		t.Errorf("expected %v got %v", ErrBodyTooLarge, err)
+	}
+
+	s.KeepExtra = true
+
+	if _, err := s.Get(37.8267, -122.4233); !errors.Is(err, ErrBodyTooLarge) {
+		t.Errorf("expected %v keeping extra fields got %v", ErrBodyTooLarge, err)
+	}
+}
+
+func TestBodyExactLimit(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(exampleJSON)
+	}))
+	defer srv.Close()
+
+	s := NewService("key")
+	s.BaseURL = srv.URL
+	s.MaxBodySize = int64(len(exampleJSON))
+
+	if _, err := s.Get(37.8267, -122.4233); err != nil {
+		t.Errorf("a body of exactly MaxBodySize should be read got %v", err)
+	}
+
+	s.KeepExtra = true
+
+	if _, err := s.Get(37.8267, -122.4233); err != nil {
+		t.Errorf("a body of exactly MaxBodySize should be read keeping extra fields got %v", err)
+	}
+
+	if b, err := readBody(bytes.NewReader(exampleJSON), int64(len(exampleJSON))-1); !errors.Is(err, ErrBodyTooLarge) {
+		t.Errorf("expected %v one byte past the limit got %d bytes %v", ErrBodyTooLarge, len(b), err)
+	}
+}
+
+func TestBodyErrorUndecodable(t *testing.T) {
+	encoding, body := "gzip", ""
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", encoding)
+		w.WriteHeader(http.StatusServiceUnavailable)
+		io.WriteString(w, body)
+	}))
+	defer srv.Close()
+
+	s := NewService("key")
+	s.BaseURL = srv.URL
+
+	var apiErr *APIError
+	if _, err := s.Get(37.8267, -122.4233); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("expected an APIError for an empty gzip body got %v", err)
+	}
+
+	encoding, body = "br", "unavailable"
+
+	if _, err := s.Get(37.8267, -122.4233); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("expected an APIError for an unsupported encoding got %v", err)
+	}
+}
+
+func TestBodyClosed(t *testing.T) {
+	status := http.StatusOK
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		w.Write(exampleJSON)
+	}))
+	defer srv.Close()
+
+	rt := &recordingTransport{}
+	s := NewService("key", WithTransport(rt))
+	s.BaseURL = srv.URL
+
+	s.Get(37.8267, -122.4233)
+	status = http.StatusInternalServerError
+	s.Get(37.8267, -122.4233)
+	s.MaxBodySize = 16
+	status = http.StatusOK
+	s.Get(37.8267, -122.4233)
+
+	if len(rt.bodies) != 3 {
+		t.Fatalf("expected 3 responses got %d", len(rt.bodies))
+	}
+
+	for i, body := range rt.bodies {
+		if !body.closed {
+			t.Errorf("body %d was not closed", i)
+		}
+	}
+}
+
+/*
+BenchmarkDecodeReadAll is the decoding path Service used before streaming:
+buffer the whole body, then unmarshal it
+*/
+func BenchmarkDecodeReadAll(b *testing.B) {
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		var res Response
+
+		body, err := ioutil.ReadAll(bytes.NewReader(exampleJSON))
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		if err := json.Unmarshal(body, &res); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+/*
+BenchmarkDecodeKeepExtra is the path Service takes with KeepExtra set: buffer
+the whole body, unmarshal it, then capture its unknown fields
+*/
+func BenchmarkDecodeKeepExtra(b *testing.B) {
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		var res Response
+
+		body, err := readBody(bytes.NewReader(exampleJSON), defaultMaxBodySize)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		if err := json.Unmarshal(body, &res); err != nil {
+			b.Fatal(err)
+		}
+
+		if err := res.CaptureExtra(body); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecodeStream(b *testing.B) {
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		var res Response
+
+		if err := decodeBody(bytes.NewReader(exampleJSON), defaultMaxBodySize, &res); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGetContext(b *testing.B) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(exampleJSON)
+	}))
+	defer srv.Close()
+
+	s := NewService("key")
+	s.BaseURL = srv.URL
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := s.GetContext(context.Background(), 37.8267, -122.4233); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
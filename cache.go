@@ -0,0 +1,321 @@
+package darksky
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultCachePrecision = 2
+	defaultCacheTTL       = 5 * time.Minute
+)
+
+/*
+Cache stores encoded responses for a limited time. Implementations must be
+safe for concurrent use.
+*/
+type Cache interface {
+	// Get returns the value stored under key, if it has not expired
+	Get(key string) ([]byte, bool)
+	// Set stores value under key for ttl
+	Set(key string, value []byte, ttl time.Duration)
+}
+
+/*
+CachedService answers requests from a Cache when it can and from the wrapped
+Service otherwise. Coordinates are rounded to Precision decimal places before
+both the lookup and the request, so nearby locations share one entry.
+*/
+type CachedService struct {
+	Service *Service
+	Cache   Cache
+	// Precision is the number of decimal places coordinates are rounded to.
+	// Two places is roughly a kilometer.
+	Precision int
+	// TTL is how long a response is kept when the API sends no caching
+	// headers
+	TTL time.Duration
+}
+
+/*
+NewCachedService wraps s with c using the default precision and TTL
+*/
+func NewCachedService(s *Service, c Cache) *CachedService {
+	return &CachedService{
+		Service:   s,
+		Cache:     c,
+		Precision: defaultCachePrecision,
+		TTL:       defaultCacheTTL,
+	}
+}
+
+/*
+Get gets a response from the cache or darksky
+*/
+func (c *CachedService) Get(lat, long float32) (Response, error) {
+	return c.GetContext(context.Background(), lat, long)
+}
+
+/*
+GetContext gets a response from the cache or darksky shaped by opts
+*/
+func (c *CachedService) GetContext(ctx context.Context, lat, long float32, opts ...RequestOption) (Response, error) {
+	r := NewRequest(opts...)
+	if err := r.Validate(); err != nil {
+		return Response{}, err
+	}
+
+	u := c.Service.URL(c.round(lat), c.round(long), r)
+	key := redact(u, c.Service.Key)
+
+	if b, ok := c.Cache.Get(key); ok {
+		cached := Response{}
+		if err := json.Unmarshal(b, &cached); err == nil && (!c.Service.KeepExtra || cached.CaptureExtra(b) == nil) {
+			cached.Localize()
+			return cached, nil
+		}
+	}
+
+	ret, meta, err := c.Service.fetch(ctx, u)
+	if err != nil {
+		return ret, err
+	}
+
+	if ttl, ok := cacheTTL(meta.Header, time.Now(), c.TTL); ok {
+		if b, err := json.Marshal(ret); err == nil {
+			c.Cache.Set(key, b, ttl)
+		}
+	}
+
+	return ret, nil
+}
+
+/*
+GetAt makes a Time Machine request through the cache
+*/
+func (c *CachedService) GetAt(ctx context.Context, lat, long float32, t time.Time, opts ...RequestOption) (Response, error) {
+	return c.GetContext(ctx, lat, long, append(append([]RequestOption(nil), opts...), At(t))...)
+}
+
+func (c *CachedService) round(v float32) float32 {
+	p := math.Pow(10, float64(c.Precision))
+	return float32(math.Round(float64(v)*p) / p)
+}
+
+/*
+cacheTTL decides how long a response may be kept from its Cache-Control and
+Expires headers, falling back to def when neither is present. It returns false
+when the response must not be cached.
+*/
+func cacheTTL(h http.Header, now time.Time, def time.Duration) (time.Duration, bool) {
+	if cc := h.Get("Cache-Control"); cc != "" {
+		for _, directive := range strings.Split(cc, ",") {
+			directive = strings.ToLower(strings.TrimSpace(directive))
+
+			switch {
+			case directive == "no-store" || directive == "no-cache":
+				return 0, false
+			case strings.HasPrefix(directive, "max-age="):
+				secs, err := strconv.Atoi(strings.TrimPrefix(directive, "max-age="))
+				if err != nil || secs <= 0 {
+					return 0, false
+				}
+				return time.Duration(secs) * time.Second, true
+			}
+		}
+	}
+
+	if exp := h.Get("Expires"); exp != "" {
+		t, err := http.ParseTime(exp)
+		if err != nil {
+			return 0, false
+		}
+
+		if date, err := http.ParseTime(h.Get("Date")); err == nil {
+			now = date
+		}
+
+		if ttl := t.Sub(now); ttl > 0 {
+			return ttl, true
+		}
+		return 0, false
+	}
+
+	return def, def > 0
+}
+
+/*
+MemoryCache is an in-memory Cache that evicts the least recently used entry
+once it holds Capacity entries
+*/
+type MemoryCache struct {
+	capacity int
+	now      func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List
+}
+
+type memoryEntry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+/*
+NewMemoryCache creates a MemoryCache holding at most capacity entries
+*/
+func NewMemoryCache(capacity int) *MemoryCache {
+	return &MemoryCache{
+		capacity: capacity,
+		now:      time.Now,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+/*
+Get implements Cache
+*/
+func (m *MemoryCache) Get(key string) ([]byte, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	el, ok := m.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	e := el.Value.(*memoryEntry)
+	if !m.now().Before(e.expires) {
+		m.order.Remove(el)
+		delete(m.entries, key)
+		return nil, false
+	}
+
+	m.order.MoveToFront(el)
+	return e.value, true
+}
+
+/*
+Set implements Cache
+*/
+func (m *MemoryCache) Set(key string, value []byte, ttl time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	expires := m.now().Add(ttl)
+
+	if el, ok := m.entries[key]; ok {
+		e := el.Value.(*memoryEntry)
+		e.value, e.expires = value, expires
+		m.order.MoveToFront(el)
+		return
+	}
+
+	m.entries[key] = m.order.PushFront(&memoryEntry{key: key, value: value, expires: expires})
+
+	for m.capacity > 0 && m.order.Len() > m.capacity {
+		el := m.order.Back()
+		m.order.Remove(el)
+		delete(m.entries, el.Value.(*memoryEntry).key)
+	}
+}
+
+/*
+Len returns the number of entries held, including expired ones not yet evicted
+*/
+func (m *MemoryCache) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.order.Len()
+}
+
+/*
+FileCache is a Cache that keeps one file per entry in a directory, so cached
+responses survive restarts
+*/
+type FileCache struct {
+	Dir string
+}
+
+/*
+NewFileCache creates a FileCache in dir, creating the directory if needed
+*/
+func NewFileCache(dir string) (*FileCache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &FileCache{Dir: dir}, nil
+}
+
+func (f *FileCache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(f.Dir, hex.EncodeToString(sum[:]))
+}
+
+/*
+Get implements Cache. Each file starts with its expiry in UNIX nanoseconds.
+*/
+func (f *FileCache) Get(key string) ([]byte, bool) {
+	p := f.path(key)
+
+	b, err := ioutil.ReadFile(p)
+	if err != nil || len(b) < 8 {
+		return nil, false
+	}
+
+	if time.Now().UnixNano() >= int64(binary.BigEndian.Uint64(b)) {
+		os.Remove(p)
+		return nil, false
+	}
+
+	return b[8:], true
+}
+
+/*
+Set implements Cache. Failures to write are ignored, leaving the entry
+uncached.
+*/
+func (f *FileCache) Set(key string, value []byte, ttl time.Duration) {
+	b := make([]byte, 8+len(value))
+	binary.BigEndian.PutUint64(b, uint64(time.Now().Add(ttl).UnixNano()))
+	copy(b[8:], value)
+
+	p := f.path(key)
+
+	tmp, err := ioutil.TempFile(f.Dir, ".tmp-")
+	if err != nil {
+		return
+	}
+
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return
+	}
+
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return
+	}
+
+	if err := os.Rename(tmp.Name(), p); err != nil {
+		os.Remove(tmp.Name())
+	}
+}
@@ -0,0 +1,159 @@
+package darksky
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestCachedService(t *testing.T) {
+	var paths []string
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Write(exampleJSON)
+	}))
+	defer srv.Close()
+
+	s := NewService("key")
+	s.BaseURL = srv.URL
+
+	c := NewCachedService(s, NewMemoryCache(16))
+
+	for _, loc := range [][2]float32{{37.8267, -122.4233}, {37.8312, -122.4249}, {37.8267, -122.4233}} {
+		res, err := c.GetContext(context.Background(), loc[0], loc[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if res.Latitude != 37.8267 {
+			t.Errorf("latitude is not correct")
+		}
+	}
+
+	if _, err := c.GetContext(context.Background(), 37.8267, -122.4233, WithUnits(UnitsSI)); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(paths) != 2 {
+		t.Fatalf("expected 2 requests got %d", len(paths))
+	}
+
+	if paths[0] != "/key/37.83,-122.42" {
+		t.Errorf("expected rounded coordinates got %s", paths[0])
+	}
+}
+
+func TestCachedServiceNoStore(t *testing.T) {
+	calls := 0
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Cache-Control", "no-store")
+		w.Write(exampleJSON)
+	}))
+	defer srv.Close()
+
+	s := NewService("key")
+	s.BaseURL = srv.URL
+
+	c := NewCachedService(s, NewMemoryCache(16))
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.Get(37.8267, -122.4233); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if calls != 2 {
+		t.Errorf("expected 2 requests got %d", calls)
+	}
+}
+
+func TestCacheTTL(t *testing.T) {
+	now := time.Date(2019, 3, 6, 15, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		header http.Header
+		ttl    time.Duration
+		ok     bool
+	}{
+		{http.Header{}, defaultCacheTTL, true},
+		{http.Header{"Cache-Control": {"public, max-age=300"}}, 5 * time.Minute, true},
+		{http.Header{"Cache-Control": {"no-cache"}}, 0, false},
+		{http.Header{"Expires": {"Wed, 06 Mar 2019 15:10:00 GMT"}}, 10 * time.Minute, true},
+		{http.Header{"Expires": {"Wed, 06 Mar 2019 15:10:00 GMT"}, "Date": {"Wed, 06 Mar 2019 15:05:00 GMT"}}, 5 * time.Minute, true},
+		{http.Header{"Expires": {"Wed, 06 Mar 2019 14:00:00 GMT"}}, 0, false},
+		{http.Header{"Expires": {"0"}}, 0, false},
+	}
+
+	for _, test := range tests {
+		ttl, ok := cacheTTL(test.header, now, defaultCacheTTL)
+		if ttl != test.ttl || ok != test.ok {
+			t.Errorf("%v expected %v %v got %v %v", test.header, test.ttl, test.ok, ttl, ok)
+		}
+	}
+}
+
+func TestMemoryCache(t *testing.T) {
+	now := time.Unix(1551886726, 0)
+
+	m := NewMemoryCache(2)
+	m.now = func() time.Time { return now }
+
+	m.Set("a", []byte("1"), time.Minute)
+	m.Set("b", []byte("2"), time.Hour)
+
+	if _, ok := m.Get("a"); !ok {
+		t.Errorf("expected a to be cached")
+	}
+
+	m.Set("c", []byte("3"), time.Hour)
+
+	if _, ok := m.Get("b"); ok {
+		t.Errorf("expected b to be evicted as least recently used")
+	}
+
+	now = now.Add(2 * time.Minute)
+
+	if _, ok := m.Get("a"); ok {
+		t.Errorf("expected a to have expired")
+	}
+
+	if v, ok := m.Get("c"); !ok || string(v) != "3" {
+		t.Errorf("expected c to be cached got %q", v)
+	}
+}
+
+func TestFileCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "darksky")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	f, err := NewFileCache(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f.Set("a", []byte("1"), time.Hour)
+	f.Set("b", []byte("2"), -time.Second)
+
+	if v, ok := f.Get("a"); !ok || string(v) != "1" {
+		t.Errorf("expected a to be cached got %q", v)
+	}
+
+	if _, ok := f.Get("b"); ok {
+		t.Errorf("expected b to have expired")
+	}
+
+	if _, ok := f.Get("c"); ok {
+		t.Errorf("expected c to be missing")
+	}
+}
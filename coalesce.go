@@ -0,0 +1,169 @@
+package darksky
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"time"
+)
+
+/*
+Coalescer shares one in-flight call between concurrent identical requests.
+Every caller waiting on a shared call receives its own copy of the Response,
+so each may change it, for example with ConvertTo, without affecting the
+others.
+*/
+type Coalescer struct {
+	mu    sync.Mutex
+	calls map[string]*flight
+	stats CoalesceStats
+}
+
+/*
+CoalesceStats counts the calls a Coalescer made and the requests it saved
+*/
+type CoalesceStats struct {
+	// Calls is the number of calls actually made
+	Calls int64
+	// Coalesced is the number of requests that joined a call already in
+	// flight instead of making their own
+	Coalesced int64
+}
+
+type flight struct {
+	done    chan struct{}
+	cancel  context.CancelFunc
+	waiters int
+
+	res  Response
+	meta ResponseMeta
+	err  error
+}
+
+/*
+NewCoalescer creates an empty Coalescer
+*/
+func NewCoalescer() *Coalescer {
+	return &Coalescer{calls: make(map[string]*flight)}
+}
+
+/*
+WithCoalescing makes concurrent identical requests share one call
+*/
+func WithCoalescing() Option {
+	return func(s *Service) {
+		s.Coalescer = NewCoalescer()
+	}
+}
+
+/*
+Stats returns a snapshot of the coalescer's counters
+*/
+func (c *Coalescer) Stats() CoalesceStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.stats
+}
+
+/*
+do runs fn once for all concurrent callers with the same key. The shared call
+is only canceled once every caller waiting on it has given up, or once
+timeout passes when it is positive, as no caller's deadline applies to it.
+*/
+func (c *Coalescer) do(ctx context.Context, key string, timeout time.Duration, fn func(context.Context) (Response, ResponseMeta, error)) (Response, ResponseMeta, error) {
+	c.mu.Lock()
+
+	f, ok := c.calls[key]
+	if ok {
+		f.waiters++
+		c.stats.Coalesced++
+	} else {
+		fctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		if timeout > 0 {
+			fctx, cancel = context.WithTimeout(context.WithoutCancel(ctx), timeout)
+		}
+
+		f = &flight{done: make(chan struct{}), cancel: cancel, waiters: 1}
+		c.calls[key] = f
+		c.stats.Calls++
+
+		go func() {
+			f.res, f.meta, f.err = fn(fctx)
+			cancel()
+
+			c.mu.Lock()
+			if c.calls[key] == f {
+				delete(c.calls, key)
+			}
+			c.mu.Unlock()
+
+			close(f.done)
+		}()
+	}
+
+	c.mu.Unlock()
+
+	select {
+	case <-f.done:
+		meta := f.meta
+		meta.Header = meta.Header.Clone()
+		return clone(reflect.ValueOf(f.res)).Interface().(Response), meta, f.err
+	case <-ctx.Done():
+		c.mu.Lock()
+		f.waiters--
+		if f.waiters == 0 {
+			f.cancel()
+			if c.calls[key] == f {
+				delete(c.calls, key)
+			}
+		}
+		c.mu.Unlock()
+
+		return Response{}, ResponseMeta{}, ctx.Err()
+	}
+}
+
+/*
+clone returns a deep copy of v. Fields that cannot be set, such as those
+inside a time.Time, are copied by value.
+*/
+func clone(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(v.Elem().Type())
+		c.Elem().Set(clone(v.Elem()))
+		return c
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(clone(v.Index(i)))
+		}
+		return c
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeMapWithSize(v.Type(), v.Len())
+		for it := v.MapRange(); it.Next(); {
+			c.SetMapIndex(it.Key(), clone(it.Value()))
+		}
+		return c
+	case reflect.Struct:
+		c := reflect.New(v.Type()).Elem()
+		c.Set(v)
+		for i := 0; i < c.NumField(); i++ {
+			if c.Field(i).CanSet() {
+				c.Field(i).Set(clone(v.Field(i)))
+			}
+		}
+		return c
+	}
+	return v
+}
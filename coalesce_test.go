@@ -0,0 +1,178 @@
+package darksky
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func waitForCoalesced(t *testing.T, c *Coalescer, n int64) {
+	for deadline := time.Now().Add(5 * time.Second); c.Stats().Coalesced < n; {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d coalesced requests got %d", n, c.Stats().Coalesced)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestCoalescing(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		w.Write(exampleJSON)
+	}))
+	defer srv.Close()
+
+	s := NewService("key", WithCoalescing())
+	s.BaseURL = srv.URL
+
+	const n = 10
+
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res, err := s.GetContext(context.Background(), 37.8267, -122.4233)
+			if err == nil && res.Latitude != 37.8267 {
+				t.Errorf("latitude is not correct")
+			}
+			errs <- err
+		}()
+	}
+
+	waitForCoalesced(t, s.Coalescer, n-1)
+	close(release)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	if calls != 1 {
+		t.Errorf("expected a single call got %d", calls)
+	}
+
+	if stats := s.Coalescer.Stats(); stats.Calls != 1 || stats.Coalesced != n-1 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestCoalescingCancelOneWaiter(t *testing.T) {
+	release := make(chan struct{})
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.Write(exampleJSON)
+	}))
+	defer srv.Close()
+
+	s := NewService("key", WithCoalescing())
+	s.BaseURL = srv.URL
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	first := make(chan error, 1)
+	go func() {
+		_, err := s.GetContext(ctx, 37.8267, -122.4233)
+		first <- err
+	}()
+
+	second := make(chan error, 1)
+	go func() {
+		_, err := s.GetContext(context.Background(), 37.8267, -122.4233)
+		second <- err
+	}()
+
+	waitForCoalesced(t, s.Coalescer, 1)
+	cancel()
+
+	if err := <-first; err != context.Canceled {
+		t.Errorf("expected %v got %v", context.Canceled, err)
+	}
+
+	close(release)
+
+	if err := <-second; err != nil {
+		t.Errorf("expected the remaining waiter to succeed got %v", err)
+	}
+}
+
+func TestCoalescingOwnCopies(t *testing.T) {
+	release := make(chan struct{})
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.Write(exampleJSON)
+	}))
+	defer srv.Close()
+
+	s := NewService("key", WithCoalescing())
+	s.BaseURL = srv.URL
+
+	const n = 4
+
+	var wg sync.WaitGroup
+	temps := make(chan float32, n)
+
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res, err := s.GetContext(context.Background(), 37.8267, -122.4233)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if err := res.ConvertTo(UnitsSI); err != nil {
+				t.Error(err)
+				return
+			}
+			temps <- *res.Currently.Temperature
+		}()
+	}
+
+	waitForCoalesced(t, s.Coalescer, n-1)
+	close(release)
+	wg.Wait()
+	close(temps)
+
+	for temp := range temps {
+		if !approx(temp, 12.8278) {
+			t.Errorf("expected each waiter to convert its own copy once got %v", temp)
+		}
+	}
+}
+
+func TestCoalescingTimeout(t *testing.T) {
+	c := NewCoalescer()
+
+	_, _, err := c.do(context.Background(), "key", 10*time.Millisecond, func(ctx context.Context) (Response, ResponseMeta, error) {
+		<-ctx.Done()
+		return Response{}, ResponseMeta{}, ctx.Err()
+	})
+
+	if err != context.DeadlineExceeded {
+		t.Errorf("expected the shared call to time out got %v", err)
+	}
+}
+
+func TestCallTimeout(t *testing.T) {
+	s := NewService("key", WithTimeout(time.Second), WithRetry(RetryPolicy{MaxAttempts: 3, MaxDelay: 2 * time.Second}))
+
+	if d := s.callTimeout(); d != 7*time.Second {
+		t.Errorf("expected 3 attempts and 2 waits got %v", d)
+	}
+}
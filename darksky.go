@@ -1,68 +1,359 @@
 package darksky
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
-	"io/ioutil"
 	"net"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 )
 
 const (
-	defaultURLFormat = "https://api.darksky.net/forecast/%s/%f,%f?exclude=minutely&units=us"
-	defaultTimeout   = 30 * time.Second
+	defaultBaseURL = "https://api.darksky.net/forecast"
+	defaultTimeout = 30 * time.Second
 )
 
+/*
+DefaultClient is the pooled client shared by every Service that is not given
+its own. Reusing it keeps connections (and their TLS sessions) alive between
+forecasts.
+*/
+var DefaultClient = &http.Client{
+	Transport: &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   defaultTimeout,
+			KeepAlive: defaultTimeout,
+		}).DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   16,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: defaultTimeout,
+		ExpectContinueTimeout: time.Second,
+	},
+}
+
 /*
 Service houses the data to call the Darksky API
 */
 type Service struct {
+	// BaseURL is the forecast endpoint; the key and location are appended as
+	// path segments.
+	BaseURL string
+	// URLFormat is the fmt format of the forecast URL, taking the key,
+	// latitude and longitude.
+	//
+	// Deprecated: use BaseURL. When set, the part of URLFormat before the key
+	// is used in place of BaseURL; its query is replaced by the request's.
 	URLFormat string
-	Key       string
-	Timeout   time.Duration
+	// TimeMachineURL is the endpoint for Time Machine requests, for APIs that
+	// serve them separately. When empty BaseURL is used.
+	TimeMachineURL string
+	// Params are extra query parameters sent with every request
+	Params url.Values
+	Key    string
+	// Timeout bounds each attempt, including reading the body. Zero disables
+	// it.
+	Timeout time.Duration
+	// Client performs the requests. When nil DefaultClient is used.
+	Client *http.Client
+	// Retry controls how transient failures are retried. When nil every call
+	// makes a single attempt.
+	Retry *RetryPolicy
+	// Limiter paces requests, including retries, when set
+	Limiter *RateLimiter
+	// Budget refuses requests once the day's calls are used up, when set
+	Budget *Budget
+	// Coalescer shares in-flight calls between identical requests, when set
+	Coalescer *Coalescer
+	// Concurrency bounds the requests GetMany and Stream make at once. Zero
+	// uses a default of 8.
+	Concurrency int
+	// KeepExtra fills the Extra fields of responses, at the cost of decoding
+	// each one twice
+	KeepExtra bool
+	// MaxBodySize caps the decoded size of a response body. Zero uses a
+	// default of 8MB.
+	MaxBodySize int64
+}
+
+/*
+Option configures a Service in NewService
+*/
+type Option func(*Service)
+
+/*
+WithHTTPClient makes the service send its requests through c
+*/
+func WithHTTPClient(c *http.Client) Option {
+	return func(s *Service) {
+		s.Client = c
+	}
+}
+
+/*
+WithTransport makes the service send its requests through rt, for example a
+proxying, tracing or test round tripper
+*/
+func WithTransport(rt http.RoundTripper) Option {
+	return func(s *Service) {
+		s.Client = &http.Client{Transport: rt}
+	}
+}
+
+/*
+WithBaseURL points the service at another Darksky-compatible endpoint
+*/
+func WithBaseURL(u string) Option {
+	return func(s *Service) {
+		s.BaseURL = u
+	}
+}
+
+/*
+WithTimeout overrides the default per-call timeout
+*/
+func WithTimeout(d time.Duration) Option {
+	return func(s *Service) {
+		s.Timeout = d
+	}
 }
 
 /*
 NewService constructs a service from an API key
 */
-func NewService(key string) *Service {
-	return &Service{
-		URLFormat: defaultURLFormat,
-		Key:       key,
-		Timeout:   defaultTimeout,
+func NewService(key string, opts ...Option) *Service {
+	s := &Service{
+		BaseURL: defaultBaseURL,
+		Key:     key,
+		Timeout: defaultTimeout,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+/*
+URL returns the forecast URL for a location and request
+*/
+func (s *Service) URL(lat, long float32, r Request) string {
+	base := s.BaseURL
+	if i := strings.Index(s.URLFormat, "/%s"); i >= 0 {
+		base = s.URLFormat[:i]
 	}
+	if !r.Time.IsZero() && s.TimeMachineURL != "" {
+		base = s.TimeMachineURL
+	}
+
+	u := strings.TrimSuffix(base, "/") + "/" + url.PathEscape(s.Key) + "/" + formatCoord(lat) + "," + formatCoord(long)
+
+	if t := r.formatTime(); t != "" {
+		u += "," + url.PathEscape(t)
+	}
+
+	q := r.Query()
+	for k, v := range s.Params {
+		if _, ok := q[k]; !ok {
+			q[k] = v
+		}
+	}
+
+	if enc := q.Encode(); enc != "" {
+		u += "?" + enc
+	}
+
+	return u
+}
+
+func formatCoord(c float32) string {
+	return strconv.FormatFloat(float64(c), 'f', -1, 32)
+}
+
+func (s *Service) client() *http.Client {
+	if s.Client != nil {
+		return s.Client
+	}
+	return DefaultClient
 }
 
 /*
 Get gets a response from darksky
 */
 func (s *Service) Get(lat, long float32) (Response, error) {
-	client := &http.Client{
-		Transport: &http.Transport{
-			Dial: (&net.Dialer{
-				Timeout:   s.Timeout,
-				KeepAlive: s.Timeout,
-			}).Dial,
-			TLSHandshakeTimeout:   s.Timeout,
-			ResponseHeaderTimeout: s.Timeout,
-			ExpectContinueTimeout: s.Timeout,
-		},
+	return s.GetContext(context.Background(), lat, long)
+}
+
+/*
+GetContext gets a response from darksky shaped by opts, aborting the request
+if ctx is canceled or its deadline passes. In that case the returned error is
+ctx.Err() so callers can test for context.Canceled or context.DeadlineExceeded
+directly. Darksky's own failures are returned as *APIError.
+*/
+func (s *Service) GetContext(ctx context.Context, lat, long float32, opts ...RequestOption) (Response, error) {
+	ret, _, err := s.GetWithMeta(ctx, lat, long, opts...)
+	return ret, err
+}
+
+/*
+GetWithMeta is GetContext that also returns the metadata Darksky sent in the
+response headers, such as the number of calls made today
+*/
+func (s *Service) GetWithMeta(ctx context.Context, lat, long float32, opts ...RequestOption) (Response, ResponseMeta, error) {
+	r := NewRequest(opts...)
+	if err := r.Validate(); err != nil {
+		return Response{}, ResponseMeta{}, err
+	}
+
+	return s.fetch(ctx, s.URL(lat, long, r))
+}
+
+/*
+fetch gets u, sharing the call with concurrent identical requests when
+s.Coalescer is set
+*/
+func (s *Service) fetch(ctx context.Context, u string) (Response, ResponseMeta, error) {
+	if s.Coalescer == nil {
+		return s.fetchRetry(ctx, u)
+	}
+
+	return s.Coalescer.do(ctx, u, s.callTimeout(), func(ctx context.Context) (Response, ResponseMeta, error) {
+		return s.fetchRetry(ctx, u)
+	})
+}
+
+/*
+callTimeout is the longest a call may take with every attempt timing out and
+every retry waiting MaxDelay, or zero when Timeout is zero
+*/
+func (s *Service) callTimeout() time.Duration {
+	if s.Timeout <= 0 {
+		return 0
+	}
+
+	d := s.Timeout
+	if s.Retry != nil {
+		for i := 1; i < s.Retry.MaxAttempts; i++ {
+			d += s.Retry.MaxDelay + s.Timeout
+		}
+	}
+	return d
+}
+
+/*
+fetchRetry gets u, retrying transient failures according to s.Retry, and
+returns the decoded response along with its metadata
+*/
+func (s *Service) fetchRetry(ctx context.Context, u string) (Response, ResponseMeta, error) {
+	for attempt := 1; ; attempt++ {
+		if err := s.Budget.allow(); err != nil {
+			return Response{}, ResponseMeta{}, err
+		}
+
+		if s.Limiter != nil {
+			if err := s.Limiter.Wait(ctx); err != nil {
+				s.Budget.release()
+				return Response{}, ResponseMeta{}, err
+			}
+		}
+
+		ret, meta, err := s.fetchOnce(ctx, u)
+		if meta.Header != nil {
+			s.Budget.record(meta.APICalls)
+		} else {
+			s.Budget.release()
+		}
+
+		if err == nil || s.Retry == nil || attempt >= s.Retry.MaxAttempts || !s.Retry.retryable(ctx, err) {
+			return ret, meta, err
+		}
+
+		if werr := wait(ctx, s.Retry.delay(attempt, err)); werr != nil {
+			if werr == errDeadlineTooSoon {
+				return ret, meta, err
+			}
+			return ret, meta, werr
+		}
+	}
+}
+
+/*
+fetchOnce makes a single attempt at getting u
+*/
+func (s *Service) fetchOnce(ctx context.Context, u string) (Response, ResponseMeta, error) {
+	if s.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.Timeout)
+		defer cancel()
 	}
 
 	ret := Response{}
 
-	if res, err := client.Get(fmt.Sprintf(s.URLFormat, s.Key, lat, long)); err != nil {
-		return ret, err
-	} else if res.StatusCode/100 != 2 {
-		return ret, fmt.Errorf("invalid statuscode from darksky: %d", res.StatusCode)
-	} else if b, err := ioutil.ReadAll(res.Body); err != nil {
-		return ret, err
-	} else if err := json.Unmarshal(b, &ret); err != nil {
-		return ret, err
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return ret, ResponseMeta{}, redactError(err, s.Key)
+	}
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	res, err := s.client().Do(req)
+	if err != nil {
+		return ret, ResponseMeta{}, redactError(contextError(ctx, err), s.Key)
 	}
+	defer closeBody(res.Body)
 
-	return ret, nil
+	meta := newResponseMeta(res)
+
+	if res.StatusCode/100 != 2 {
+		return ret, meta, newAPIError(res, errorBody(res), s.Key)
+	}
+
+	body, err := openBody(res)
+	if err != nil {
+		return ret, meta, err
+	}
+
+	if s.KeepExtra {
+		// the unknown fields need the raw document, so it is read whole
+		if b, err := readBody(body, s.maxBodySize()); err != nil {
+			return ret, meta, contextError(ctx, err)
+		} else if err := json.Unmarshal(b, &ret); err != nil {
+			return ret, meta, err
+		} else if err := ret.CaptureExtra(b); err != nil {
+			return ret, meta, err
+		}
+	} else if err := decodeBody(body, s.maxBodySize(), &ret); err != nil {
+		return ret, meta, contextError(ctx, err)
+	}
+
+	ret.Localize()
+
+	return ret, meta, nil
+}
+
+/*
+GetAt makes a Time Machine request for the observed or forecast conditions at
+a location at time t
+*/
+func (s *Service) GetAt(ctx context.Context, lat, long float32, t time.Time, opts ...RequestOption) (Response, error) {
+	return s.GetContext(ctx, lat, long, append(append([]RequestOption(nil), opts...), At(t))...)
+}
+
+/*
+contextError prefers the context's own error over the transport error that
+wraps it, so cancellation is never mistaken for a Darksky failure
+*/
+func contextError(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	return err
 }
 
 /*
@@ -76,8 +367,13 @@ type Response struct {
 	Minutely  *DataSummary `json:"minutely,omitempty"`
 	Hourly    *DataSummary `json:"hourly,omitempty"`
 	Daily     *DataSummary `json:"daily,omitempty"`
+	Alerts    []Alert      `json:"alerts,omitempty"`
 	Flags     Flags        `json:"flags"`
-	Offset    int          `json:"offset"`
+	Offset    float32      `json:"offset"`
+	// Extra holds fields Darksky sent that this package does not know about,
+	// so they survive being decoded and encoded again. It is only filled by
+	// CaptureExtra.
+	Extra map[string]json.RawMessage `json:"-"`
 }
 
 /*
@@ -85,61 +381,107 @@ Flags give additional metadata from Darksky
 */
 type Flags struct {
 	Sources        []string `json:"sources"`
-	NearestStation float32  `json:"nearest-station"`
-	Units          string   `json:"units"`
+	NearestStation *float32 `json:"nearest-station,omitempty"`
+	Units          Units    `json:"units"`
+	// SourceTimes and Version are sent by Pirate Weather: the model run each
+	// source is from, and the API version
+	SourceTimes map[string]string `json:"sourceTimes,omitempty"`
+	Version     string            `json:"version,omitempty"`
+	// Extra holds unknown fields, see Response.Extra
+	Extra map[string]json.RawMessage `json:"-"`
 }
 
-type UnixTime time.Time
-
-func (u *UnixTime) UnmarshalJSON(b []byte) error {
-	t := int64(0)
-
-	if err := json.Unmarshal(b, &t); err != nil {
-		return err
-	}
-
-	*u = UnixTime(time.Unix(t, 0))
-	return nil
+/*
+Data is a struct to hold a set of weather data. Darksky leaves out whatever
+it does not know for a point, so optional values are pointers that are nil
+when absent rather than zero; use Float32 to set them, Float32Value to read
+them with a zero default, or Has and Get to look them up by name.
+*/
+type Data struct {
+	Time                        UnixTime   `json:"time"`
+	Summary                     string     `json:"summary,omitempty"`
+	Icon                        Icon       `json:"icon,omitempty"`
+	SunriseTime                 *UnixTime  `json:"sunriseTime,omitempty"`
+	SunsetTime                  *UnixTime  `json:"sunsetTime,omitempty"`
+	MoonPhase                   *float32   `json:"moonPhase,omitempty"`
+	NearestStormDistance        *float32   `json:"nearestStormDistance,omitempty"`
+	NearestStormBearing         *float32   `json:"nearestStormBearing,omitempty"`
+	PrecipIntensity             *float32   `json:"precipIntensity,omitempty"`
+	PrecipIntensityError        *float32   `json:"precipIntensityError,omitempty"`
+	PrecipIntensityMax          *float32   `json:"precipIntensityMax,omitempty"`
+	PrecipIntensityMaxTime      *UnixTime  `json:"precipIntensityMaxTime,omitempty"`
+	PrecipProbability           *float32   `json:"precipProbability,omitempty"`
+	PrecipType                  PrecipType `json:"precipType,omitempty"`
+	PrecipAccumulation          *float32   `json:"precipAccumulation,omitempty"`
+	Temperature                 *float32   `json:"temperature,omitempty"`
+	ApparentTemperature         *float32   `json:"apparentTemperature,omitempty"`
+	TemperatureLow              *float32   `json:"temperatureLow,omitempty"`
+	TemperatureHighTime         *UnixTime  `json:"temperatureHighTime,omitempty"`
+	TemperatureHigh             *float32   `json:"temperatureHigh,omitempty"`
+	TemperatureLowTime          *UnixTime  `json:"temperatureLowTime,omitempty"`
+	ApparentTemperatureHigh     *float32   `json:"apparentTemperatureHigh,omitempty"`
+	ApparentTemperatureHighTime *UnixTime  `json:"apparentTemperatureHighTime,omitempty"`
+	ApparentTemperatureLow      *float32   `json:"apparentTemperatureLow,omitempty"`
+	ApparentTemperatureLowTime  *UnixTime  `json:"apparentTemperatureLowTime,omitempty"`
+	DewPoint                    *float32   `json:"dewPoint,omitempty"`
+	Humidity                    *float32   `json:"humidity,omitempty"`
+	Pressure                    *float32   `json:"pressure,omitempty"`
+	WindSpeed                   *float32   `json:"windSpeed,omitempty"`
+	WindGust                    *float32   `json:"windGust,omitempty"`
+	WindGustTime                *UnixTime  `json:"windGustTime,omitempty"`
+	WindBearing                 *float32   `json:"windBearing,omitempty"`
+	CloudCover                  *float32   `json:"cloudCover,omitempty"`
+	UVIndex                     *float32   `json:"uvIndex,omitempty"`
+	UVIndexTime                 *UnixTime  `json:"uvIndexTime,omitempty"`
+	Visibility                  *float32   `json:"visibility,omitempty"`
+	Ozone                       *float32   `json:"ozone,omitempty"`
+	// The Min and Max fields are deprecated by Darksky in favor of the Low and
+	// High fields above, which span the night rather than the calendar day
+	TemperatureMin             *float32  `json:"temperatureMin,omitempty"`
+	TemperatureMinTime         *UnixTime `json:"temperatureMinTime,omitempty"`
+	TemperatureMax             *float32  `json:"temperatureMax,omitempty"`
+	TemperatureMaxTime         *UnixTime `json:"temperatureMaxTime,omitempty"`
+	ApparentTemperatureMin     *float32  `json:"apparentTemperatureMin,omitempty"`
+	ApparentTemperatureMinTime *UnixTime `json:"apparentTemperatureMinTime,omitempty"`
+	ApparentTemperatureMax     *float32  `json:"apparentTemperatureMax,omitempty"`
+	ApparentTemperatureMaxTime *UnixTime `json:"apparentTemperatureMaxTime,omitempty"`
+	// Pirate Weather extensions, sent with version=2
+	FeelsLike          *float32 `json:"feelsLike,omitempty"`
+	LiquidAccumulation *float32 `json:"liquidAccumulation,omitempty"`
+	SnowAccumulation   *float32 `json:"snowAccumulation,omitempty"`
+	IceAccumulation    *float32 `json:"iceAccumulation,omitempty"`
+	Smoke              *float32 `json:"smoke,omitempty"`
+	FireIndex          *float32 `json:"fireIndex,omitempty"`
+	Cape               *float32 `json:"cape,omitempty"`
+	Solar              *float32 `json:"solar,omitempty"`
+	// Extra holds unknown fields, see Response.Extra
+	Extra map[string]json.RawMessage `json:"-"`
 }
 
-func (u UnixTime) MarshalJSON() ([]byte, error) {
-	return json.Marshal(time.Time(u).Unix())
+/*
+Float32 returns a pointer to v, for setting optional Data fields
+*/
+func Float32(v float32) *float32 {
+	return &v
 }
 
 /*
-Data is a struct to hold a set of weather data
+Float32Value returns the value p points to, or zero if p is nil
 */
-type Data struct {
-	Time                 UnixTime  `json:"time"`
-	Summary              string    `json:"summary,omitempty"`
-	Icon                 string    `json:"icon"`
-	NearestStormDistance float32   `json:"nearestStormDistance"`
-	PrecipIntensity      float32   `json:"precipIntensity"`
-	PrecipProbability    float32   `json:"precipProbability"`
-	PrecipType           string    `json:"precipType,omitempty"`
-	Temperature          *float32  `json:"temperature,omitempty"`
-	ApparentTemperature  *float32  `json:"apparentTemperature,omitempty"`
-	TemperatureLow       *float32  `json:"temperatureLow,omitempty"`
-	TemperatureHighTime  *UnixTime `json:"temperatureHighTime,omitempty"`
-	TemperatureHigh      *float32  `json:"temperatureHigh,omitempty"`
-	TemperatureLowTime   *UnixTime `json:"temperatureLowTime,omitempty"`
-	DewPoint             *float32  `json:"dewPoint"`
-	Humidity             float32   `json:"humidity"`
-	Pressure             float32   `json:"pressure"`
-	WindSpeed            float32   `json:"windSpeed"`
-	WindGust             float32   `json:"windGust"`
-	WindBearing          float32   `json:"windBearing"`
-	CloudCover           float32   `json:"cloudCover"`
-	UVIndex              float32   `json:"uvIndex"`
-	Visibility           float32   `json:"visibility"`
-	Ozone                float32   `json:"ozone"`
+func Float32Value(p *float32) float32 {
+	if p == nil {
+		return 0
+	}
+	return *p
 }
 
 /*
 DataSummary wraps an array of Data elements along with an icon and summary
 */
 type DataSummary struct {
-	Summary string `json:"summary"`
-	Icon    string `json:"icon"`
+	Summary string `json:"summary,omitempty"`
+	Icon    Icon   `json:"icon,omitempty"`
 	Data    []Data `json:"data"`
+	// Extra holds unknown fields, see Response.Extra
+	Extra map[string]json.RawMessage `json:"-"`
 }
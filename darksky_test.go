@@ -1,7 +1,11 @@
 package darksky
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 )
@@ -24,7 +28,7 @@ func TestDarkskyUnmarshal(t *testing.T) {
 		t.Errorf("latitude is not correct")
 	}
 
-	if res.Currently.Time != UnixTime(time.Unix(1551886726, 0)) {
+	if !res.Currently.Time.Equal(UnixTime(time.Unix(1551886726, 0))) {
 		t.Errorf("expected current time %v got %v", time.Unix(1551886726, 0), res.Currently.Time)
 	}
 
@@ -40,6 +44,7 @@ func TestDarkskyUnmarshal(t *testing.T) {
 }
 
 func TestDarkskyMarshal(t *testing.T) {
+	stormDistance := float32(0)
 	temp := float32(55.09)
 	appTemp := float32(55.09)
 	dewPoint := float32(50.97)
@@ -55,21 +60,21 @@ func TestDarkskyMarshal(t *testing.T) {
 			Time:                 UnixTime(time.Unix(1551886726, 0)),
 			Summary:              "Mostly Cloudy",
 			Icon:                 "partly-cloudy-day",
-			NearestStormDistance: 0,
-			PrecipIntensity:      0,
-			PrecipProbability:    0,
+			NearestStormDistance: &stormDistance,
+			PrecipIntensity:      Float32(0),
+			PrecipProbability:    Float32(0),
 			Temperature:          &temp,
 			ApparentTemperature:  &appTemp,
 			DewPoint:             &dewPoint,
-			Humidity:             0.86,
-			Pressure:             1003.16,
-			WindSpeed:            10.93,
-			WindGust:             18.43,
-			WindBearing:          205,
-			CloudCover:           0.79,
-			UVIndex:              0,
-			Visibility:           6.9,
-			Ozone:                353.42,
+			Humidity:             Float32(0.86),
+			Pressure:             Float32(1003.16),
+			WindSpeed:            Float32(10.93),
+			WindGust:             Float32(18.43),
+			WindBearing:          Float32(205),
+			CloudCover:           Float32(0.79),
+			UVIndex:              Float32(0),
+			Visibility:           Float32(6.9),
+			Ozone:                Float32(353.42),
 		},
 		Hourly: &DataSummary{
 			Summary: "Mostly cloudy throughout the day and breezy until this afternoon.",
@@ -79,21 +84,21 @@ func TestDarkskyMarshal(t *testing.T) {
 					Time:                UnixTime(time.Unix(1551884400, 0)),
 					Summary:             "Rain",
 					Icon:                "rain",
-					PrecipIntensity:     0.0583,
-					PrecipProbability:   0.98,
+					PrecipIntensity:     Float32(0.0583),
+					PrecipProbability:   Float32(0.98),
 					PrecipType:          "rain",
 					Temperature:         &hourTemp,
 					ApparentTemperature: &appHourTemp,
 					DewPoint:            &hourDewPoint,
-					Humidity:            0.87,
-					Pressure:            1002.53,
-					WindSpeed:           10.72,
-					WindGust:            18.81,
-					WindBearing:         202,
-					CloudCover:          0.83,
-					UVIndex:             0,
-					Visibility:          6.22,
-					Ozone:               354.65,
+					Humidity:            Float32(0.87),
+					Pressure:            Float32(1002.53),
+					WindSpeed:           Float32(10.72),
+					WindGust:            Float32(18.81),
+					WindBearing:         Float32(202),
+					CloudCover:          Float32(0.83),
+					UVIndex:             Float32(0),
+					Visibility:          Float32(6.22),
+					Ozone:               Float32(354.65),
 				},
 			},
 		},
@@ -111,14 +116,203 @@ func TestDarkskyMarshal(t *testing.T) {
 				"sref",
 				"darksky",
 			},
-			NearestStation: 1.839,
+			NearestStation: Float32(1.839),
 			Units:          "us",
 		},
 	}
 
 	if b, err := json.Marshal(res); err != nil {
 		t.Error(err)
-	} else if string(b) != `{"latitude":37.8267,"longitude":-122.4233,"timezone":"America/Los_Angeles","currently":{"time":1551886726,"summary":"Mostly Cloudy","icon":"partly-cloudy-day","nearestStormDistance":0,"precipIntensity":0,"precipProbability":0,"temperature":55.09,"apparentTemperature":55.09,"dewPoint":50.97,"humidity":0.86,"pressure":1003.16,"windSpeed":10.93,"windGust":18.43,"windBearing":205,"cloudCover":0.79,"uvIndex":0,"visibility":6.9,"ozone":353.42},"hourly":{"summary":"Mostly cloudy throughout the day and breezy until this afternoon.","icon":"wind","data":[{"time":1551884400,"summary":"Rain","icon":"rain","nearestStormDistance":0,"precipIntensity":0.0583,"precipProbability":0.98,"precipType":"rain","temperature":54.92,"apparentTemperature":54.92,"dewPoint":51.07,"humidity":0.87,"pressure":1002.53,"windSpeed":10.72,"windGust":18.81,"windBearing":202,"cloudCover":0.83,"uvIndex":0,"visibility":6.22,"ozone":354.65}]},"flags":{"sources":["nearest-precip","nwspa","cmc","gfs","hrrr","icon","isd","madis","nam","sref","darksky"],"nearest-station":1.839,"units":"us"},"offset":0}` {
+	} else if string(b) != `{"latitude":37.8267,"longitude":-122.4233,"timezone":"America/Los_Angeles","currently":{"time":1551886726,"summary":"Mostly Cloudy","icon":"partly-cloudy-day","nearestStormDistance":0,"precipIntensity":0,"precipProbability":0,"temperature":55.09,"apparentTemperature":55.09,"dewPoint":50.97,"humidity":0.86,"pressure":1003.16,"windSpeed":10.93,"windGust":18.43,"windBearing":205,"cloudCover":0.79,"uvIndex":0,"visibility":6.9,"ozone":353.42},"hourly":{"summary":"Mostly cloudy throughout the day and breezy until this afternoon.","icon":"wind","data":[{"time":1551884400,"summary":"Rain","icon":"rain","precipIntensity":0.0583,"precipProbability":0.98,"precipType":"rain","temperature":54.92,"apparentTemperature":54.92,"dewPoint":51.07,"humidity":0.87,"pressure":1002.53,"windSpeed":10.72,"windGust":18.81,"windBearing":202,"cloudCover":0.83,"uvIndex":0,"visibility":6.22,"ozone":354.65}]},"flags":{"sources":["nearest-precip","nwspa","cmc","gfs","hrrr","icon","isd","madis","nam","sref","darksky"],"nearest-station":1.839,"units":"us"},"offset":0}` {
 		t.Errorf("not marshaled properly, got %s", string(b))
 	}
 }
+
+func TestDarkskyGetContext(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(exampleJSON)
+	}))
+	defer srv.Close()
+
+	s := NewService("key")
+	s.BaseURL = srv.URL
+
+	res, err := s.GetContext(context.Background(), 37.8267, -122.4233)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Latitude != 37.8267 {
+		t.Errorf("latitude is not correct")
+	}
+}
+
+func TestDarkskyGetContextDeadline(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	s := NewService("key")
+	s.BaseURL = srv.URL
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if _, err := s.GetContext(ctx, 37.8267, -122.4233); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected %v got %v", context.DeadlineExceeded, err)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := s.GetContext(ctx, 37.8267, -122.4233); err != context.Canceled {
+		t.Errorf("expected %v got %v", context.Canceled, err)
+	}
+}
+
+type countingTransport struct {
+	calls int
+	rt    http.RoundTripper
+}
+
+func (c *countingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	c.calls++
+	return c.rt.RoundTrip(r)
+}
+
+func TestDarkskyWithTransport(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(exampleJSON)
+	}))
+	defer srv.Close()
+
+	rt := &countingTransport{rt: http.DefaultTransport}
+	s := NewService("key", WithTransport(rt), WithTimeout(time.Second))
+	s.BaseURL = srv.URL
+
+	for i := 0; i < 2; i++ {
+		if _, err := s.Get(37.8267, -122.4233); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if rt.calls != 2 {
+		t.Errorf("expected 2 calls through the transport got %d", rt.calls)
+	}
+}
+
+var (
+	dailyJSON = []byte(`
+	{"latitude":39.7392,"longitude":-104.9903,"timezone":"America/Denver","currently":{"time":1551886726,"summary":"Light Snow","icon":"snow","nearestStormDistance":12,"nearestStormBearing":291,"precipIntensity":0.0162,"precipIntensityError":0.0043,"precipProbability":0.52,"precipType":"snow","temperature":24.93,"apparentTemperature":15.6,"dewPoint":19.27,"humidity":0.79,"pressure":1021.6,"windSpeed":9.32,"windGust":14.51,"windBearing":17,"cloudCover":1,"uvIndex":0,"visibility":2.31,"ozone":386.54},"daily":{"summary":"Light snow today through Friday, with high temperatures bottoming out at 26°F on Thursday.","icon":"snow","data":[{"time":1551855600,"summary":"Light snow throughout the day.","icon":"snow","sunriseTime":1551878386,"sunsetTime":1551919906,"moonPhase":0.01,"precipIntensity":0.0081,"precipIntensityMax":0.0241,"precipIntensityMaxTime":1551906000,"precipProbability":0.86,"precipType":"snow","precipAccumulation":2.713,"temperatureHigh":28.32,"temperatureHighTime":1551909600,"temperatureLow":17.88,"temperatureLowTime":1551963600,"apparentTemperatureHigh":21.62,"apparentTemperatureHighTime":1551909600,"apparentTemperatureLow":9.73,"apparentTemperatureLowTime":1551963600,"dewPoint":17.58,"humidity":0.78,"pressure":1022.45,"windSpeed":6.18,"windGust":17.45,"windGustTime":1551920400,"windBearing":23,"cloudCover":1,"uvIndex":2,"uvIndexTime":1551898800,"visibility":3.53,"ozone":381.64,"temperatureMin":20.72,"temperatureMinTime":1551934800,"temperatureMax":28.32,"temperatureMaxTime":1551909600,"apparentTemperatureMin":12.05,"apparentTemperatureMinTime":1551934800,"apparentTemperatureMax":21.62,"apparentTemperatureMaxTime":1551909600}]},"flags":{"sources":["nearest-precip","nwspa","cmc","gfs","hrrr","icon","isd","madis","nam","sref","darksky"],"nearest-station":2.431,"units":"us"},"offset":-7}
+	`)
+)
+
+func TestDarkskyUnmarshalDaily(t *testing.T) {
+	var res Response
+
+	if err := json.Unmarshal(dailyJSON, &res); err != nil {
+		t.Fatal(err)
+	}
+
+	c := res.Currently
+	if c.NearestStormDistance == nil || *c.NearestStormDistance != 12 || c.NearestStormBearing == nil || *c.NearestStormBearing != 291 {
+		t.Errorf("nearest storm not decoded: %v %v", c.NearestStormDistance, c.NearestStormBearing)
+	}
+
+	if c.PrecipIntensityError == nil || *c.PrecipIntensityError != 0.0043 {
+		t.Errorf("precipIntensityError not decoded: %v", c.PrecipIntensityError)
+	}
+
+	if len(res.Daily.Data) != 1 {
+		t.Fatalf("expected a single day got %d", len(res.Daily.Data))
+	}
+
+	d := res.Daily.Data[0]
+
+	floats := []struct {
+		name string
+		got  *float32
+		want float32
+	}{
+		{"moonPhase", d.MoonPhase, 0.01},
+		{"precipIntensityMax", d.PrecipIntensityMax, 0.0241},
+		{"precipAccumulation", d.PrecipAccumulation, 2.713},
+		{"apparentTemperatureHigh", d.ApparentTemperatureHigh, 21.62},
+		{"apparentTemperatureLow", d.ApparentTemperatureLow, 9.73},
+		{"temperatureMin", d.TemperatureMin, 20.72},
+		{"temperatureMax", d.TemperatureMax, 28.32},
+		{"apparentTemperatureMin", d.ApparentTemperatureMin, 12.05},
+		{"apparentTemperatureMax", d.ApparentTemperatureMax, 21.62},
+	}
+
+	for _, f := range floats {
+		if f.got == nil || *f.got != f.want {
+			t.Errorf("%s expected %v got %v", f.name, f.want, f.got)
+		}
+	}
+
+	times := []struct {
+		name string
+		got  *UnixTime
+		want int64
+	}{
+		{"sunriseTime", d.SunriseTime, 1551878386},
+		{"sunsetTime", d.SunsetTime, 1551919906},
+		{"precipIntensityMaxTime", d.PrecipIntensityMaxTime, 1551906000},
+		{"apparentTemperatureHighTime", d.ApparentTemperatureHighTime, 1551909600},
+		{"apparentTemperatureLowTime", d.ApparentTemperatureLowTime, 1551963600},
+		{"windGustTime", d.WindGustTime, 1551920400},
+		{"uvIndexTime", d.UVIndexTime, 1551898800},
+		{"temperatureMinTime", d.TemperatureMinTime, 1551934800},
+		{"temperatureMaxTime", d.TemperatureMaxTime, 1551909600},
+		{"apparentTemperatureMinTime", d.ApparentTemperatureMinTime, 1551934800},
+		{"apparentTemperatureMaxTime", d.ApparentTemperatureMaxTime, 1551909600},
+	}
+
+	for _, tm := range times {
+		if tm.got == nil || !tm.got.Equal(UnixTime(time.Unix(tm.want, 0))) {
+			t.Errorf("%s expected %v got %v", tm.name, time.Unix(tm.want, 0), tm.got)
+		}
+	}
+
+	if d.Temperature != nil || d.NearestStormDistance != nil {
+		t.Errorf("daily data should not have a temperature or nearest storm")
+	}
+}
+
+func TestDarkskyMissingValues(t *testing.T) {
+	var res Response
+
+	if err := json.Unmarshal(exampleJSON, &res); err != nil {
+		t.Fatal(err)
+	}
+
+	minute := res.Minutely.Data[0]
+
+	if minute.Humidity != nil {
+		t.Errorf("minutely data should have no humidity got %v", minute.Humidity)
+	}
+
+	if minute.PrecipProbability == nil || *minute.PrecipProbability != 0 {
+		t.Errorf("minutely data should have a zero precipProbability got %v", minute.PrecipProbability)
+	}
+
+	if res.Currently.Humidity == nil || *res.Currently.Humidity != 0.86 {
+		t.Errorf("expected current humidity 0.86 got %v", res.Currently.Humidity)
+	}
+
+	if Float32Value(minute.Pressure) != 0 {
+		t.Errorf("missing values should read as zero")
+	}
+
+	b, err := json.Marshal(minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(b) != `{"time":1551886680,"precipIntensity":0,"precipProbability":0}` {
+		t.Errorf("missing values should be omitted, got %s", b)
+	}
+}
@@ -0,0 +1,155 @@
+package darksky
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	maxErrorBodySize = 64 << 10
+	redactedKey      = "REDACTED"
+)
+
+/*
+Sentinel errors matched by *APIError through errors.Is
+*/
+var (
+	// ErrBadRequest is a malformed request, such as an invalid location or time
+	ErrBadRequest = errors.New("darksky: bad request")
+	// ErrUnauthorized is a missing, invalid or revoked API key
+	ErrUnauthorized = errors.New("darksky: unauthorized")
+	// ErrNotFound is an unknown endpoint
+	ErrNotFound = errors.New("darksky: not found")
+	// ErrQuotaExceeded is an exhausted daily call allowance
+	ErrQuotaExceeded = errors.New("darksky: usage limit exceeded")
+	// ErrServerError is a 5xx outage on Darksky's side
+	ErrServerError = errors.New("darksky: server error")
+	// ErrBudgetExceeded is returned without calling Darksky once a Service's
+	// daily Budget is used up
+	ErrBudgetExceeded = errors.New("darksky: daily budget exceeded")
+)
+
+/*
+APIError is returned when Darksky answers with a non-2xx status
+*/
+type APIError struct {
+	// StatusCode is the HTTP status of the response
+	StatusCode int `json:"-"`
+	// Code and Message are decoded from Darksky's JSON error body, if any
+	Code    int    `json:"code"`
+	Message string `json:"error"`
+	// URL is the request URL with the API key redacted
+	URL string `json:"-"`
+	// RetryAfter is how long the server asked clients to wait, if it did
+	RetryAfter time.Duration `json:"-"`
+}
+
+func (e *APIError) Error() string {
+	msg := e.Message
+	if msg == "" {
+		msg = http.StatusText(e.StatusCode)
+	}
+
+	return fmt.Sprintf("darksky: %d %s (%s)", e.StatusCode, msg, e.URL)
+}
+
+/*
+Is matches e against the package's sentinel errors
+*/
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrBadRequest:
+		return e.StatusCode == http.StatusBadRequest
+	case ErrUnauthorized:
+		return (e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden) && !e.quota()
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrQuotaExceeded:
+		return e.quota()
+	case ErrServerError:
+		return e.StatusCode/100 == 5
+	}
+	return false
+}
+
+/*
+quota reports whether the error is Darksky refusing calls past the daily
+limit, which it signals with a 403 rather than a 429
+*/
+func (e *APIError) quota() bool {
+	if e.StatusCode == http.StatusTooManyRequests {
+		return true
+	}
+
+	return e.StatusCode == http.StatusForbidden && strings.Contains(strings.ToLower(e.Message), "limit")
+}
+
+/*
+newAPIError builds an APIError from a non-2xx response, decoding as much of
+its body as Darksky sent
+*/
+func newAPIError(res *http.Response, body io.Reader, key string) *APIError {
+	e := &APIError{
+		StatusCode: res.StatusCode,
+		URL:        redact(res.Request.URL.String(), key),
+		RetryAfter: parseRetryAfter(res.Header.Get("Retry-After")),
+	}
+
+	b, err := ioutil.ReadAll(io.LimitReader(body, maxErrorBodySize))
+	if err != nil || len(b) == 0 {
+		return e
+	}
+
+	if err := json.Unmarshal(b, e); err != nil || e.Message == "" {
+		e.Message = errorMessage(b)
+	}
+
+	return e
+}
+
+/*
+errorMessage finds the human readable message in an error body from one of
+the other providers, which name it differently, falling back to the raw body
+*/
+func errorMessage(b []byte) string {
+	var body map[string]interface{}
+	if err := json.Unmarshal(b, &body); err == nil {
+		for _, k := range []string{"error", "reason", "detail", "message", "title"} {
+			if msg, ok := body[k].(string); ok && msg != "" {
+				return msg
+			}
+		}
+	}
+
+	return strings.TrimSpace(string(b))
+}
+
+/*
+redact removes the API key from a URL so it can be logged
+*/
+func redact(u, key string) string {
+	if key == "" {
+		return u
+	}
+
+	u = strings.Replace(u, url.PathEscape(key), redactedKey, -1)
+	return strings.Replace(u, url.QueryEscape(key), redactedKey, -1)
+}
+
+/*
+redactError removes the API key from the URL carried by transport errors
+*/
+func redactError(err error, key string) error {
+	var uerr *url.Error
+	if errors.As(err, &uerr) {
+		uerr.URL = redact(uerr.URL, key)
+	}
+	return err
+}
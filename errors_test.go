@@ -0,0 +1,69 @@
+package darksky
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAPIError(t *testing.T) {
+	tests := []struct {
+		status int
+		body   string
+		is     error
+		msg    string
+	}{
+		{400, `{"code":400,"error":"The given location is invalid."}`, ErrBadRequest, "The given location is invalid."},
+		{403, `{"code":403,"error":"permission denied"}`, ErrUnauthorized, "permission denied"},
+		{403, `{"code":403,"error":"daily usage limit exceeded"}`, ErrQuotaExceeded, "daily usage limit exceeded"},
+		{404, `{"code":404,"error":"Not Found"}`, ErrNotFound, "Not Found"},
+		{429, ``, ErrQuotaExceeded, ""},
+		{503, `Service Unavailable`, ErrServerError, "Service Unavailable"},
+	}
+
+	for _, test := range tests {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(test.status)
+			w.Write([]byte(test.body))
+		}))
+
+		s := NewService("secret")
+		s.BaseURL = srv.URL
+
+		_, err := s.GetContext(context.Background(), 37.8267, -122.4233)
+		srv.Close()
+
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) {
+			t.Errorf("expected *APIError got %v", err)
+			continue
+		}
+
+		if apiErr.StatusCode != test.status || apiErr.Message != test.msg {
+			t.Errorf("expected %d %q got %d %q", test.status, test.msg, apiErr.StatusCode, apiErr.Message)
+		}
+
+		if !errors.Is(err, test.is) {
+			t.Errorf("expected %v to be %v", err, test.is)
+		}
+
+		if strings.Contains(err.Error(), "secret") || !strings.Contains(apiErr.URL, redactedKey) {
+			t.Errorf("key not redacted in %s", err)
+		}
+	}
+}
+
+func TestAPIErrorSentinelsAreExclusive(t *testing.T) {
+	err := &APIError{StatusCode: 403, Message: "daily usage limit exceeded"}
+
+	if errors.Is(err, ErrUnauthorized) {
+		t.Errorf("quota errors should not be unauthorized")
+	}
+
+	if errors.Is(&APIError{StatusCode: 500}, ErrBadRequest) {
+		t.Errorf("server errors should not be bad requests")
+	}
+}
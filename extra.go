@@ -0,0 +1,282 @@
+package darksky
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+/*
+Known JSON fields of each type that captures unknown fields in Extra
+*/
+var (
+	responseFields    = jsonFields(reflect.TypeOf(Response{}))
+	flagsFields       = jsonFields(reflect.TypeOf(Flags{}))
+	dataFields        = jsonFields(reflect.TypeOf(Data{}))
+	dataSummaryFields = jsonFields(reflect.TypeOf(DataSummary{}))
+	alertFields       = jsonFields(reflect.TypeOf(Alert{}))
+)
+
+/*
+jsonFields returns the JSON names of the fields of struct type t
+*/
+func jsonFields(t reflect.Type) map[string]bool {
+	fields := make(map[string]bool, t.NumField())
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name == "-" || f.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		fields[name] = true
+	}
+
+	return fields
+}
+
+/*
+unmarshalExtra returns the members of the JSON object b that are not in known,
+or nil if there are none
+*/
+func unmarshalExtra(b []byte, known map[string]bool) (map[string]json.RawMessage, error) {
+	all := map[string]json.RawMessage{}
+	if err := json.Unmarshal(b, &all); err != nil {
+		return nil, err
+	}
+	return extraOf(all, known), nil
+}
+
+/*
+extraOf returns the members of a decoded object that are not in known, or nil
+if there are none
+*/
+func extraOf(all map[string]json.RawMessage, known map[string]bool) map[string]json.RawMessage {
+	var extra map[string]json.RawMessage
+
+	for k, v := range all {
+		if known[k] {
+			continue
+		}
+		if extra == nil {
+			extra = make(map[string]json.RawMessage)
+		}
+		extra[k] = v
+	}
+
+	return extra
+}
+
+/*
+marshalExtra appends the members of extra to the JSON object b in key order.
+Members that collide with a known field are dropped so the typed value wins.
+*/
+func marshalExtra(b []byte, extra map[string]json.RawMessage, known map[string]bool) ([]byte, error) {
+	if len(extra) == 0 {
+		return b, nil
+	}
+
+	keys := make([]string, 0, len(extra))
+	for k := range extra {
+		if !known[k] {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	buf := bytes.NewBuffer(make([]byte, 0, len(b)+64*len(keys)))
+	buf.Write(b[:len(b)-1])
+
+	empty := len(bytes.TrimSpace(b[1:len(b)-1])) == 0
+
+	for _, k := range keys {
+		if !empty {
+			buf.WriteByte(',')
+		}
+		empty = false
+
+		key, err := json.Marshal(k)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+
+		if err := json.Compact(buf, extra[k]); err != nil {
+			return nil, err
+		}
+	}
+
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+/*
+WithExtraFields makes the service keep the fields Darksky sends that this
+package does not declare, see Response.CaptureExtra
+*/
+func WithExtraFields() Option {
+	return func(s *Service) {
+		s.KeepExtra = true
+	}
+}
+
+/*
+CaptureExtra fills the Extra fields of a response decoded from b with the
+members of each object that this package does not declare. Capturing them
+decodes b a second time, so plain decoding leaves Extra empty and callers
+that re-emit responses opt in by calling CaptureExtra after json.Unmarshal.
+*/
+func (r *Response) CaptureExtra(b []byte) error {
+	doc := map[string]json.RawMessage{}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return err
+	}
+
+	r.Extra = extraOf(doc, responseFields)
+
+	var err error
+
+	if raw, ok := doc["flags"]; ok {
+		if r.Flags.Extra, err = unmarshalExtra(raw, flagsFields); err != nil {
+			return err
+		}
+	}
+
+	if raw, ok := doc["currently"]; ok && r.Currently != nil {
+		if r.Currently.Extra, err = unmarshalExtra(raw, dataFields); err != nil {
+			return err
+		}
+	}
+
+	for name, block := range map[string]*DataSummary{"minutely": r.Minutely, "hourly": r.Hourly, "daily": r.Daily} {
+		if raw, ok := doc[name]; ok && block != nil {
+			if err := block.captureExtra(raw); err != nil {
+				return err
+			}
+		}
+	}
+
+	if raw, ok := doc["alerts"]; ok {
+		var alerts []json.RawMessage
+		if err := json.Unmarshal(raw, &alerts); err != nil {
+			return err
+		}
+		for i := 0; i < len(alerts) && i < len(r.Alerts); i++ {
+			if r.Alerts[i].Extra, err = unmarshalExtra(alerts[i], alertFields); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *DataSummary) captureExtra(b []byte) error {
+	block := map[string]json.RawMessage{}
+	if err := json.Unmarshal(b, &block); err != nil {
+		return err
+	}
+
+	s.Extra = extraOf(block, dataSummaryFields)
+
+	var data []json.RawMessage
+	if raw, ok := block["data"]; ok {
+		if err := json.Unmarshal(raw, &data); err != nil {
+			return err
+		}
+	}
+
+	for i := 0; i < len(data) && i < len(s.Data); i++ {
+		var err error
+		if s.Data[i].Extra, err = unmarshalExtra(data[i], dataFields); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+/*
+MarshalJSON encodes the response, including the fields in Extra. Flags are
+left out when empty so a document without them is not given any.
+*/
+func (r Response) MarshalJSON() ([]byte, error) {
+	type response Response
+
+	// Offset is restated so it stays after flags
+	v := struct {
+		response
+		Flags  *Flags  `json:"flags,omitempty"`
+		Offset float32 `json:"offset"`
+	}{response: response(r), Offset: r.Offset}
+
+	if !reflect.ValueOf(r.Flags).IsZero() {
+		v.Flags = &r.Flags
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return marshalExtra(b, r.Extra, responseFields)
+}
+
+/*
+MarshalJSON encodes the flags, including the fields in Extra
+*/
+func (f Flags) MarshalJSON() ([]byte, error) {
+	type flags Flags
+
+	b, err := json.Marshal(flags(f))
+	if err != nil {
+		return nil, err
+	}
+	return marshalExtra(b, f.Extra, flagsFields)
+}
+
+/*
+MarshalJSON encodes the data point, including the fields in Extra
+*/
+func (d Data) MarshalJSON() ([]byte, error) {
+	type data Data
+
+	b, err := json.Marshal(data(d))
+	if err != nil {
+		return nil, err
+	}
+	return marshalExtra(b, d.Extra, dataFields)
+}
+
+/*
+MarshalJSON encodes the data block, including the fields in Extra
+*/
+func (s DataSummary) MarshalJSON() ([]byte, error) {
+	type dataSummary DataSummary
+
+	b, err := json.Marshal(dataSummary(s))
+	if err != nil {
+		return nil, err
+	}
+	return marshalExtra(b, s.Extra, dataSummaryFields)
+}
+
+/*
+MarshalJSON encodes the alert, including the fields in Extra
+*/
+func (a Alert) MarshalJSON() ([]byte, error) {
+	type alert Alert
+
+	b, err := json.Marshal(alert(a))
+	if err != nil {
+		return nil, err
+	}
+	return marshalExtra(b, a.Extra, alertFields)
+}
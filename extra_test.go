@@ -0,0 +1,181 @@
+package darksky
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+/*
+withUnknownFields adds fields this package does not declare to every level of
+the dailyJSON fixture
+*/
+func withUnknownFields() []byte {
+	b := bytes.Replace(dailyJSON, []byte(`"offset":-7}`), []byte(`"offset":-7,"elevation":1609,"source":{"model":"hrrr","runs":[6,12]}}`), 1)
+	b = bytes.Replace(b, []byte(`"units":"us"}`), []byte(`"units":"us","processTime":12,"sourceIDX":{"hrrr":{"x":400,"y":1000},"gfs":{"x":1004,"y":208}}}`), 1)
+	b = bytes.Replace(b, []byte(`"ozone":386.54}`), []byte(`"ozone":386.54,"smokeMax":0.71,"currentDayIce":null}`), 1)
+	b = bytes.Replace(b, []byte(`"icon":"snow","data"`), []byte(`"icon":"snow","extended":false,"data"`), 1)
+	return b
+}
+
+/*
+withoutFlags is a document with neither flags nor a nearest station, which
+must not gain them when encoded again
+*/
+var withoutFlags = []byte(`{"latitude":47.6062,"longitude":-122.3321,"timezone":"America/Los_Angeles","alerts":[{"title":"Flood Watch","regions":["King"],"severity":"watch","time":1551886726,"expires":1551973126,"description":"Flooding is possible.","uri":"https://alerts.weather.gov/flood"}],"offset":-8,"elevation":56}`)
+
+/*
+withoutNearestStation has flags but no nearest station, and
+atNearestStation a station at a distance of zero
+*/
+var (
+	withoutNearestStation = []byte(`{"latitude":47.6062,"longitude":-122.3321,"timezone":"America/Los_Angeles","flags":{"sources":["isd"],"units":"us"},"offset":-8}`)
+	atNearestStation      = []byte(`{"latitude":47.6062,"longitude":-122.3321,"timezone":"America/Los_Angeles","flags":{"sources":["isd"],"nearest-station":0,"units":"us"},"offset":-8}`)
+)
+
+func TestExtraFields(t *testing.T) {
+	b := withUnknownFields()
+
+	var res Response
+	if err := json.Unmarshal(b, &res); err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Extra != nil || res.Flags.Extra != nil || res.Currently.Extra != nil {
+		t.Errorf("expected plain decoding to leave extra empty")
+	}
+
+	if err := res.CaptureExtra(b); err != nil {
+		t.Fatal(err)
+	}
+
+	if string(res.Extra["elevation"]) != "1609" {
+		t.Errorf("expected elevation in response extra got %v", res.Extra)
+	}
+
+	if string(res.Flags.Extra["processTime"]) != "12" {
+		t.Errorf("expected processTime in flags extra got %v", res.Flags.Extra)
+	}
+
+	if string(res.Currently.Extra["smokeMax"]) != "0.71" || string(res.Currently.Extra["currentDayIce"]) != "null" {
+		t.Errorf("expected smokeMax and currentDayIce in currently extra got %v", res.Currently.Extra)
+	}
+
+	if string(res.Daily.Extra["extended"]) != "false" {
+		t.Errorf("expected extended in daily extra got %v", res.Daily.Extra)
+	}
+
+	if _, ok := res.Currently.Extra["temperature"]; ok || res.Daily.Data[0].Extra != nil {
+		t.Errorf("known fields should not be captured")
+	}
+}
+
+func TestExtraFieldsRoundTrip(t *testing.T) {
+	for _, in := range [][]byte{withUnknownFields(), withoutFlags, withoutNearestStation, atNearestStation, exampleJSON, timeMachineJSON, alertsJSON} {
+		testRoundTrip(t, in)
+	}
+}
+
+func testRoundTrip(t *testing.T, in []byte) {
+	var res Response
+	if err := json.Unmarshal(in, &res); err != nil {
+		t.Fatal(err)
+	}
+	if err := res.CaptureExtra(in); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := json.Marshal(res)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var want, got interface{}
+	if err := json.Unmarshal(in, &want); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(out, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("round trip changed the document:\nwant %s\ngot  %s", in, out)
+	}
+}
+
+func TestExtraFieldsService(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(withUnknownFields())
+	}))
+	defer srv.Close()
+
+	for _, keep := range []bool{false, true} {
+		s := NewService("key")
+		s.BaseURL = srv.URL
+		if keep {
+			WithExtraFields()(s)
+		}
+
+		res, err := s.GetContext(context.Background(), 39.7392, -104.9903)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := res.Extra["elevation"] != nil && res.Daily.Extra["extended"] != nil; got != keep {
+			t.Errorf("with KeepExtra %v expected extra captured %v got %v", keep, keep, got)
+		}
+	}
+}
+
+func TestExtraFieldsKnownWins(t *testing.T) {
+	d := Data{
+		Summary: "Clear",
+		Extra: map[string]json.RawMessage{
+			"summary":  json.RawMessage(`"Stale"`),
+			"smokeMax": json.RawMessage(` 0.5 `),
+		},
+	}
+
+	b, err := json.Marshal(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var back map[string]json.RawMessage
+	if err := json.Unmarshal(b, &back); err != nil {
+		t.Fatal(err)
+	}
+
+	if string(back["summary"]) != `"Clear"` || string(back["smokeMax"]) != "0.5" {
+		t.Errorf("unexpected encoding %s", b)
+	}
+}
+
+func BenchmarkUnmarshal(b *testing.B) {
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		var res Response
+		if err := json.Unmarshal(exampleJSON, &res); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkUnmarshalCaptureExtra(b *testing.B) {
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		var res Response
+		if err := json.Unmarshal(exampleJSON, &res); err != nil {
+			b.Fatal(err)
+		}
+		if err := res.CaptureExtra(exampleJSON); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
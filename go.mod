@@ -1 +1,3 @@
 module github.com/donniet/darksky
+
+go 1.21
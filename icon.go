@@ -0,0 +1,143 @@
+package darksky
+
+/*
+Icon is a machine-readable summary of the weather suitable for picking an
+icon. Darksky may add values at any time; unknown values decode and encode
+unchanged, so always handle a default case.
+*/
+type Icon string
+
+const (
+	IconClearDay          Icon = "clear-day"
+	IconClearNight        Icon = "clear-night"
+	IconRain              Icon = "rain"
+	IconSnow              Icon = "snow"
+	IconSleet             Icon = "sleet"
+	IconWind              Icon = "wind"
+	IconFog               Icon = "fog"
+	IconCloudy            Icon = "cloudy"
+	IconPartlyCloudyDay   Icon = "partly-cloudy-day"
+	IconPartlyCloudyNight Icon = "partly-cloudy-night"
+	// IconHail, IconThunderstorm and IconTornado are reserved by Darksky for
+	// future use
+	IconHail         Icon = "hail"
+	IconThunderstorm Icon = "thunderstorm"
+	IconTornado      Icon = "tornado"
+)
+
+/*
+Category groups icons by the kind of weather they show
+*/
+type Category string
+
+const (
+	CategoryUnknown       Category = "unknown"
+	CategoryClear         Category = "clear"
+	CategoryCloudy        Category = "cloudy"
+	CategoryPrecipitation Category = "precipitation"
+	CategoryWind          Category = "wind"
+	CategoryFog           Category = "fog"
+	CategorySevere        Category = "severe"
+)
+
+/*
+Known reports whether i is one of the documented icons
+*/
+func (i Icon) Known() bool {
+	return i.Category() != CategoryUnknown
+}
+
+/*
+IsDaytime reports whether i is specific to the daytime
+*/
+func (i Icon) IsDaytime() bool {
+	return i == IconClearDay || i == IconPartlyCloudyDay
+}
+
+/*
+IsNighttime reports whether i is specific to the nighttime
+*/
+func (i Icon) IsNighttime() bool {
+	return i == IconClearNight || i == IconPartlyCloudyNight
+}
+
+/*
+Category returns the kind of weather i shows
+*/
+func (i Icon) Category() Category {
+	switch i {
+	case IconClearDay, IconClearNight:
+		return CategoryClear
+	case IconCloudy, IconPartlyCloudyDay, IconPartlyCloudyNight:
+		return CategoryCloudy
+	case IconRain, IconSnow, IconSleet:
+		return CategoryPrecipitation
+	case IconWind:
+		return CategoryWind
+	case IconFog:
+		return CategoryFog
+	case IconHail, IconThunderstorm, IconTornado:
+		return CategorySevere
+	}
+	return CategoryUnknown
+}
+
+/*
+Emoji returns an emoji depicting i, or the empty string for unknown icons
+*/
+func (i Icon) Emoji() string {
+	switch i {
+	case IconClearDay:
+		return "☀️"
+	case IconClearNight:
+		return "🌙"
+	case IconRain:
+		return "🌧️"
+	case IconSnow:
+		return "❄️"
+	case IconSleet:
+		return "🌨️"
+	case IconWind:
+		return "💨"
+	case IconFog:
+		return "🌫️"
+	case IconCloudy:
+		return "☁️"
+	case IconPartlyCloudyDay:
+		return "⛅"
+	case IconPartlyCloudyNight:
+		return "☁️"
+	case IconHail:
+		return "🧊"
+	case IconThunderstorm:
+		return "⛈️"
+	case IconTornado:
+		return "🌪️"
+	}
+	return ""
+}
+
+/*
+PrecipType is the kind of precipitation expected. Like Icon, unknown values
+are preserved.
+*/
+type PrecipType string
+
+const (
+	PrecipRain  PrecipType = "rain"
+	PrecipSnow  PrecipType = "snow"
+	PrecipSleet PrecipType = "sleet"
+	// PrecipNone is sent by Pirate Weather when no precipitation is expected
+	PrecipNone PrecipType = "none"
+)
+
+/*
+Known reports whether p is one of the documented precipitation types
+*/
+func (p PrecipType) Known() bool {
+	switch p {
+	case PrecipRain, PrecipSnow, PrecipSleet, PrecipNone:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,71 @@
+package darksky
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestIcon(t *testing.T) {
+	tests := []struct {
+		icon      Icon
+		category  Category
+		daytime   bool
+		nighttime bool
+	}{
+		{IconClearDay, CategoryClear, true, false},
+		{IconClearNight, CategoryClear, false, true},
+		{IconPartlyCloudyDay, CategoryCloudy, true, false},
+		{IconPartlyCloudyNight, CategoryCloudy, false, true},
+		{IconCloudy, CategoryCloudy, false, false},
+		{IconRain, CategoryPrecipitation, false, false},
+		{IconSleet, CategoryPrecipitation, false, false},
+		{IconWind, CategoryWind, false, false},
+		{IconFog, CategoryFog, false, false},
+		{IconTornado, CategorySevere, false, false},
+		{"meteor-shower", CategoryUnknown, false, false},
+	}
+
+	for _, test := range tests {
+		if got := test.icon.Category(); got != test.category {
+			t.Errorf("%s expected category %s got %s", test.icon, test.category, got)
+		}
+
+		if got := test.icon.IsDaytime(); got != test.daytime {
+			t.Errorf("%s expected daytime %v got %v", test.icon, test.daytime, got)
+		}
+
+		if got := test.icon.IsNighttime(); got != test.nighttime {
+			t.Errorf("%s expected nighttime %v got %v", test.icon, test.nighttime, got)
+		}
+
+		if known := test.category != CategoryUnknown; known != test.icon.Known() || known != (test.icon.Emoji() != "") {
+			t.Errorf("%s expected known %v", test.icon, known)
+		}
+	}
+}
+
+func TestIconPreservesUnknown(t *testing.T) {
+	var d Data
+
+	if err := json.Unmarshal([]byte(`{"time":0,"icon":"meteor-shower","precipType":"frogs"}`), &d); err != nil {
+		t.Fatal(err)
+	}
+
+	if d.Icon != "meteor-shower" || d.Icon.Known() || d.PrecipType != "frogs" || d.PrecipType.Known() {
+		t.Errorf("unknown values not preserved: %q %q", d.Icon, d.PrecipType)
+	}
+
+	b, err := json.Marshal(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var back Data
+	if err := json.Unmarshal(b, &back); err != nil {
+		t.Fatal(err)
+	}
+
+	if back.Icon != d.Icon || back.PrecipType != d.PrecipType {
+		t.Errorf("unknown values not re-encoded: %s", b)
+	}
+}
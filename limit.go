@@ -0,0 +1,247 @@
+package darksky
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+ResponseMeta is the metadata Darksky returns in the headers of a response
+*/
+type ResponseMeta struct {
+	StatusCode int
+	// APICalls is the number of calls made with the key today, or -1 if the
+	// header was missing
+	APICalls int
+	// ResponseTime is the server-side processing time, if reported
+	ResponseTime time.Duration
+	Header       http.Header
+}
+
+func newResponseMeta(res *http.Response) ResponseMeta {
+	meta := ResponseMeta{
+		StatusCode: res.StatusCode,
+		APICalls:   -1,
+		Header:     res.Header,
+	}
+
+	if calls, err := strconv.Atoi(strings.TrimSpace(res.Header.Get("X-Forecast-API-Calls"))); err == nil {
+		meta.APICalls = calls
+	}
+
+	if d, err := time.ParseDuration(strings.TrimSpace(res.Header.Get("X-Response-Time"))); err == nil {
+		meta.ResponseTime = d
+	}
+
+	return meta
+}
+
+/*
+RateLimiter is a token bucket allowing Rate requests per second on average
+with bursts of up to Burst requests
+*/
+type RateLimiter struct {
+	rate  float64
+	burst float64
+	now   func() time.Time
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+/*
+NewRateLimiter creates a RateLimiter with a full bucket
+*/
+func NewRateLimiter(perSecond float64, burst int) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &RateLimiter{
+		rate:   perSecond,
+		burst:  float64(burst),
+		now:    time.Now,
+		tokens: float64(burst),
+	}
+}
+
+/*
+WithRateLimit paces the service's requests to perSecond with bursts of burst
+*/
+func WithRateLimit(perSecond float64, burst int) Option {
+	return func(s *Service) {
+		s.Limiter = NewRateLimiter(perSecond, burst)
+	}
+}
+
+/*
+Wait blocks until a request may be made or ctx ends. A token whose wait would
+outlast ctx's deadline is not taken.
+*/
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	d := l.reserve()
+	if d <= 0 {
+		return nil
+	}
+
+	if err := wait(ctx, d); err != nil {
+		l.cancel()
+		if err == errDeadlineTooSoon {
+			return context.DeadlineExceeded
+		}
+		return err
+	}
+
+	return nil
+}
+
+/*
+reserve takes a token, possibly going into debt, and returns how long the
+caller must wait for it
+*/
+func (l *RateLimiter) reserve() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if !l.last.IsZero() {
+		l.tokens += now.Sub(l.last).Seconds() * l.rate
+		if l.tokens > l.burst {
+			l.tokens = l.burst
+		}
+	}
+	l.last = now
+
+	l.tokens--
+	if l.tokens >= 0 || l.rate <= 0 {
+		return 0
+	}
+
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
+
+func (l *RateLimiter) cancel() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.tokens++
+}
+
+/*
+Budget tracks calls made against Darksky's daily quota, which resets at
+midnight UTC, and refuses calls beyond Limit
+*/
+type Budget struct {
+	Limit int
+
+	now   func() time.Time
+	mu    sync.Mutex
+	day   time.Time
+	calls int
+	// pending counts the calls allowed but not yet recorded or released
+	pending int
+}
+
+/*
+NewBudget creates a Budget of limit calls per day
+*/
+func NewBudget(limit int) *Budget {
+	return &Budget{Limit: limit, now: time.Now}
+}
+
+/*
+WithDailyBudget refuses requests once limit calls have been made today
+*/
+func WithDailyBudget(limit int) Option {
+	return func(s *Service) {
+		s.Budget = NewBudget(limit)
+	}
+}
+
+/*
+Calls returns the number of calls made today as far as the budget knows
+*/
+func (b *Budget) Calls() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.rollover()
+	return b.calls
+}
+
+func (b *Budget) rollover() {
+	now := time.Now
+	if b.now != nil {
+		now = b.now
+	}
+
+	if day := now().UTC().Truncate(24 * time.Hour); !day.Equal(b.day) {
+		b.day, b.calls = day, 0
+	}
+}
+
+/*
+allow reserves a call, refusing it when the calls made and in flight already
+use up the day's limit. Every allowed call must be followed by record or
+release.
+*/
+func (b *Budget) allow() error {
+	if b == nil {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.rollover()
+	if b.Limit > 0 && b.calls+b.pending >= b.Limit {
+		return ErrBudgetExceeded
+	}
+	b.pending++
+	return nil
+}
+
+/*
+release gives back a reserved call that was not billed, as no response came
+*/
+func (b *Budget) release() {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.pending > 0 {
+		b.pending--
+	}
+}
+
+/*
+record notes a completed reserved call, trusting Darksky's own count when it
+sent one
+*/
+func (b *Budget) record(apiCalls int) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.pending > 0 {
+		b.pending--
+	}
+
+	b.rollover()
+	if apiCalls >= 0 {
+		b.calls = apiCalls
+	} else {
+		b.calls++
+	}
+}
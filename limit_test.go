@@ -0,0 +1,154 @@
+package darksky
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestResponseMeta(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Forecast-API-Calls", "42")
+		w.Header().Set("X-Response-Time", "128.452ms")
+		w.Write(exampleJSON)
+	}))
+	defer srv.Close()
+
+	s := NewService("key")
+	s.BaseURL = srv.URL
+
+	_, meta, err := s.GetWithMeta(context.Background(), 37.8267, -122.4233)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if meta.StatusCode != http.StatusOK || meta.APICalls != 42 || meta.ResponseTime != 128452*time.Microsecond {
+		t.Errorf("unexpected meta %+v", meta)
+	}
+}
+
+func TestDailyBudget(t *testing.T) {
+	calls := 0
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Write(exampleJSON)
+	}))
+	defer srv.Close()
+
+	now := time.Date(2019, 3, 6, 23, 0, 0, 0, time.UTC)
+
+	s := NewService("key", WithDailyBudget(2))
+	s.BaseURL = srv.URL
+	s.Budget.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if _, err := s.Get(37.8267, -122.4233); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := s.Get(37.8267, -122.4233); !errors.Is(err, ErrBudgetExceeded) {
+		t.Errorf("expected %v got %v", ErrBudgetExceeded, err)
+	}
+
+	if calls != 2 || s.Budget.Calls() != 2 {
+		t.Errorf("expected 2 calls got %d (budget %d)", calls, s.Budget.Calls())
+	}
+
+	now = now.Add(2 * time.Hour)
+
+	if _, err := s.Get(37.8267, -122.4233); err != nil {
+		t.Errorf("expected the budget to reset at midnight UTC got %v", err)
+	}
+}
+
+func TestDailyBudgetConcurrent(t *testing.T) {
+	var mu sync.Mutex
+	calls := 0
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		calls++
+		mu.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+		w.Write(exampleJSON)
+	}))
+	defer srv.Close()
+
+	s := NewService("key", WithDailyBudget(5))
+	s.BaseURL = srv.URL
+	s.Concurrency = 20
+
+	locs := make([]Location, 20)
+	for i := range locs {
+		locs[i] = Location{Latitude: float32(i), Longitude: -122.4233}
+	}
+
+	refused := 0
+	for _, res := range s.GetMany(context.Background(), locs) {
+		if errors.Is(res.Err, ErrBudgetExceeded) {
+			refused++
+		} else if res.Err != nil {
+			t.Error(res.Err)
+		}
+	}
+
+	if calls != 5 || refused != 15 || s.Budget.Calls() != 5 {
+		t.Errorf("expected exactly 5 calls and 15 refused got %d and %d (budget %d)", calls, refused, s.Budget.Calls())
+	}
+}
+
+func TestDailyBudgetReleased(t *testing.T) {
+	s := NewService("key", WithDailyBudget(1))
+	s.BaseURL = "http://127.0.0.1:1"
+
+	// a request that never reaches the server is not billed
+	if _, err := s.Get(37.8267, -122.4233); err == nil || errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("expected a connection error got %v", err)
+	}
+
+	if _, err := s.Get(37.8267, -122.4233); errors.Is(err, ErrBudgetExceeded) {
+		t.Errorf("expected the unbilled call to be given back got %v", err)
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Unix(1551886726, 0)
+
+	l := NewRateLimiter(2, 2)
+	l.now = func() time.Time { return now }
+
+	waits := []time.Duration{0, 0, 500 * time.Millisecond, time.Second}
+	for i, want := range waits {
+		if got := l.reserve(); got != want {
+			t.Errorf("reservation %d expected %v got %v", i, want, got)
+		}
+	}
+
+	now = now.Add(2 * time.Second)
+
+	if got := l.reserve(); got != 0 {
+		t.Errorf("expected the bucket to have refilled got %v", got)
+	}
+}
+
+func TestRateLimiterDeadline(t *testing.T) {
+	l := NewRateLimiter(0.1, 1)
+
+	if err := l.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if err := l.Wait(ctx); err != context.DeadlineExceeded {
+		t.Errorf("expected %v got %v", context.DeadlineExceeded, err)
+	}
+}
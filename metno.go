@@ -0,0 +1,522 @@
+package darksky
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	metNorwayBaseURL = "https://api.met.no/weatherapi/locationforecast/2.0/complete"
+
+	defaultMETNorwayCacheSize = 64
+)
+
+/*
+METNorway is a keyless Provider backed by the MET Norway Locationforecast API.
+Following its terms of service, every request carries a User-Agent and
+forecasts are cached per location until they expire, then revalidated with
+If-Modified-Since. MET Norway blocks requests without a descriptive
+User-Agent. The API has no history, so TimeMachine returns ErrUnsupported.
+*/
+type METNorway struct {
+	HTTPConfig
+	BaseURL string
+	// Zone is the time zone of the response and of the days in the daily
+	// block. Locationforecast does not report one, so when nil it is
+	// estimated from the longitude.
+	Zone *time.Location
+	// CacheSize bounds the locations whose forecasts are kept, evicting the
+	// least recently used. Zero uses a default of 64.
+	CacheSize int
+
+	mu        sync.Mutex
+	forecasts map[string]*list.Element
+	order     *list.List
+	// now is replaced in tests
+	now func() time.Time
+}
+
+/*
+NewMETNorway constructs a METNorway provider that identifies itself as
+userAgent. MET Norway asks for the application's name and a way to contact
+its author, such as "acmeweather.com support@acmeweather.com".
+*/
+func NewMETNorway(userAgent string) *METNorway {
+	return &METNorway{
+		HTTPConfig: HTTPConfig{Timeout: defaultTimeout, UserAgent: userAgent},
+		BaseURL:    metNorwayBaseURL,
+		now:        time.Now,
+	}
+}
+
+/*
+metForecast is a cached forecast with the headers needed to revalidate it
+*/
+type metForecast struct {
+	key          string
+	body         metResponse
+	lastModified string
+	expires      time.Time
+}
+
+type metResponse struct {
+	Properties struct {
+		Timeseries []metStep `json:"timeseries"`
+	} `json:"properties"`
+}
+
+type metStep struct {
+	Time time.Time `json:"time"`
+	Data struct {
+		Instant struct {
+			Details metDetails `json:"details"`
+		} `json:"instant"`
+		Next1Hours  *metPeriod `json:"next_1_hours"`
+		Next6Hours  *metPeriod `json:"next_6_hours"`
+		Next12Hours *metPeriod `json:"next_12_hours"`
+	} `json:"data"`
+}
+
+type metPeriod struct {
+	Summary struct {
+		SymbolCode string `json:"symbol_code"`
+	} `json:"summary"`
+	Details metDetails `json:"details"`
+}
+
+type metDetails struct {
+	AirPressureAtSeaLevel      *float64 `json:"air_pressure_at_sea_level"`
+	AirTemperature             *float64 `json:"air_temperature"`
+	AirTemperatureMax          *float64 `json:"air_temperature_max"`
+	AirTemperatureMin          *float64 `json:"air_temperature_min"`
+	CloudAreaFraction          *float64 `json:"cloud_area_fraction"`
+	DewPointTemperature        *float64 `json:"dew_point_temperature"`
+	RelativeHumidity           *float64 `json:"relative_humidity"`
+	UltravioletIndexClearSky   *float64 `json:"ultraviolet_index_clear_sky"`
+	WindFromDirection          *float64 `json:"wind_from_direction"`
+	WindSpeed                  *float64 `json:"wind_speed"`
+	WindSpeedOfGust            *float64 `json:"wind_speed_of_gust"`
+	PrecipitationAmount        *float64 `json:"precipitation_amount"`
+	ProbabilityOfPrecipitation *float64 `json:"probability_of_precipitation"`
+}
+
+/*
+Forecast implements Provider. Currently is the forecast for the current hour.
+*/
+func (m *METNorway) Forecast(ctx context.Context, lat, long float32, opts ...RequestOption) (Response, error) {
+	r := NewRequest(opts...)
+	if err := r.Validate(); err != nil {
+		return Response{}, err
+	}
+
+	body, err := m.forecast(ctx, lat, long)
+	if err != nil {
+		return Response{}, err
+	}
+
+	res := Response{
+		Latitude:  lat,
+		Longitude: long,
+		Flags: Flags{
+			Sources: []string{"met.no"},
+		},
+	}
+
+	now := m.clock()
+	loc := m.Zone
+	if loc == nil {
+		// the solar time zone, which is close to the civil one in most places
+		loc = time.FixedZone("", int(math.Round(float64(long)/15))*3600)
+	}
+	if loc.String() != "" {
+		res.Timezone = loc.String()
+	}
+	_, offset := now.In(loc).Zone()
+	res.Offset = float32(offset) / 3600
+
+	steps := body.Properties.Timeseries
+	data := make([]Data, len(steps))
+	for i, s := range steps {
+		data[i] = metData(s)
+	}
+
+	hours := 49
+	if r.ExtendHourly {
+		hours = 169
+	}
+
+	var hourly []Data
+	for _, d := range trimData(data, now.Truncate(time.Hour), len(data)) {
+		// only the first days are hourly, the rest of the series is 6 hourly
+		if d.PrecipIntensity == nil || len(hourly) == hours {
+			break
+		}
+		hourly = append(hourly, d)
+	}
+
+	if !r.Excludes(BlockCurrently) && len(hourly) > 0 {
+		current := hourly[0]
+		res.Currently = &current
+	}
+
+	if !r.Excludes(BlockHourly) {
+		res.Hourly = &DataSummary{Data: hourly}
+	}
+
+	if !r.Excludes(BlockDaily) {
+		res.Daily = &DataSummary{Data: metDays(steps, data, loc)}
+	}
+
+	if err := finishSI(&res, r); err != nil {
+		return Response{}, err
+	}
+
+	return res, nil
+}
+
+/*
+TimeMachine implements Provider. Locationforecast has no history.
+*/
+func (m *METNorway) TimeMachine(ctx context.Context, lat, long float32, t time.Time, opts ...RequestOption) (Response, error) {
+	return Response{}, ErrUnsupported
+}
+
+func (m *METNorway) clock() time.Time {
+	if m.now != nil {
+		return m.now()
+	}
+	return time.Now()
+}
+
+/*
+forecast returns the forecast for a location, from the cache until it
+expires and then revalidated with If-Modified-Since
+*/
+func (m *METNorway) forecast(ctx context.Context, lat, long float32) (metResponse, error) {
+	q := url.Values{}
+	q.Set("lat", roundCoord(lat))
+	q.Set("lon", roundCoord(long))
+	key := q.Encode()
+
+	cached, ok := m.cached(key)
+
+	if ok && m.clock().Before(cached.expires) {
+		return cached.body, nil
+	}
+
+	header := http.Header{}
+	if ok && cached.lastModified != "" {
+		header.Set("If-Modified-Since", cached.lastModified)
+	}
+
+	f := metForecast{key: key}
+
+	res, err := m.getJSON(ctx, m.BaseURL+"?"+key, header, "", &f.body)
+	if err != nil {
+		return metResponse{}, err
+	}
+
+	f.lastModified = res.Header.Get("Last-Modified")
+	if res.StatusCode == http.StatusNotModified {
+		f.body = cached.body
+		if f.lastModified == "" {
+			f.lastModified = cached.lastModified
+		}
+	}
+
+	if t, err := http.ParseTime(res.Header.Get("Expires")); err == nil {
+		f.expires = t
+	}
+
+	m.cache(f)
+
+	return f.body, nil
+}
+
+/*
+cached returns the forecast kept for key, even once it has expired, as it
+is still needed to revalidate
+*/
+func (m *METNorway) cached(key string) (metForecast, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	el, ok := m.forecasts[key]
+	if !ok {
+		return metForecast{}, false
+	}
+
+	m.order.MoveToFront(el)
+	return *el.Value.(*metForecast), true
+}
+
+/*
+cache keeps f, evicting the least recently used forecasts past CacheSize
+*/
+func (m *METNorway) cache(f metForecast) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.forecasts == nil {
+		m.forecasts = make(map[string]*list.Element)
+		m.order = list.New()
+	}
+
+	if el, ok := m.forecasts[f.key]; ok {
+		*el.Value.(*metForecast) = f
+		m.order.MoveToFront(el)
+		return
+	}
+
+	m.forecasts[f.key] = m.order.PushFront(&f)
+
+	size := m.CacheSize
+	if size <= 0 {
+		size = defaultMETNorwayCacheSize
+	}
+
+	for m.order.Len() > size {
+		el := m.order.Back()
+		m.order.Remove(el)
+		delete(m.forecasts, el.Value.(*metForecast).key)
+	}
+}
+
+/*
+metData converts a step of the timeseries in SI units. Precipitation and the
+icon come from the next hour when the step has one and the next 6 hours
+otherwise.
+*/
+func metData(s metStep) Data {
+	in := s.Data.Instant.Details
+
+	d := Data{
+		Time:        UnixTime(s.Time),
+		Temperature: optional(in.AirTemperature),
+		DewPoint:    optional(in.DewPointTemperature),
+		Humidity:    percent(in.RelativeHumidity),
+		Pressure:    optional(in.AirPressureAtSeaLevel),
+		CloudCover:  percent(in.CloudAreaFraction),
+		UVIndex:     optional(in.UltravioletIndexClearSky),
+		WindSpeed:   optional(in.WindSpeed),
+		WindGust:    optional(in.WindSpeedOfGust),
+		WindBearing: optional(in.WindFromDirection),
+	}
+
+	if p := s.Data.Next1Hours; p != nil {
+		d.PrecipIntensity = optional(p.Details.PrecipitationAmount)
+		d.PrecipProbability = percent(p.Details.ProbabilityOfPrecipitation)
+		d.Icon, d.Summary = metIcon(p.Summary.SymbolCode), metSummary(p.Summary.SymbolCode)
+	} else if p := s.Data.Next6Hours; p != nil {
+		d.PrecipProbability = percent(p.Details.ProbabilityOfPrecipitation)
+		d.Icon, d.Summary = metIcon(p.Summary.SymbolCode), metSummary(p.Summary.SymbolCode)
+	}
+
+	switch d.Icon {
+	case IconRain, IconThunderstorm:
+		d.PrecipType = PrecipRain
+	case IconSnow:
+		d.PrecipType = PrecipSnow
+	case IconSleet:
+		d.PrecipType = PrecipSleet
+	}
+
+	return d
+}
+
+/*
+metDays aggregates the timeseries into one Data per day in loc. Each step's
+precipitation covers the next hour or the next 6 hours, and is only counted
+where it does not overlap the period of an earlier step.
+*/
+func metDays(steps []metStep, data []Data, loc *time.Location) []Data {
+	var days []Data
+	var covered time.Time
+	var totals []float64
+
+	for i, s := range steps {
+		t := s.Time.In(loc)
+		midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+
+		if len(days) == 0 || !days[len(days)-1].Time.Time().Equal(midnight) {
+			days = append(days, Data{Time: UnixTime(midnight)})
+			totals = append(totals, 0)
+		}
+		day := &days[len(days)-1]
+		d := data[i]
+
+		temps := []*float64{s.Data.Instant.Details.AirTemperature}
+		if p := s.Data.Next6Hours; p != nil {
+			temps = append(temps, p.Details.AirTemperatureMax, p.Details.AirTemperatureMin)
+		}
+		for _, v := range temps {
+			if v == nil {
+				continue
+			}
+			if day.TemperatureHigh == nil || float32(*v) > *day.TemperatureHigh {
+				day.TemperatureHigh = optional(v)
+			}
+			if day.TemperatureLow == nil || float32(*v) < *day.TemperatureLow {
+				day.TemperatureLow = optional(v)
+			}
+		}
+		day.TemperatureMax, day.TemperatureMin = day.TemperatureHigh, day.TemperatureLow
+
+		amount, period := (*float64)(nil), time.Duration(0)
+		if p := s.Data.Next1Hours; p != nil {
+			amount, period = p.Details.PrecipitationAmount, time.Hour
+		} else if p := s.Data.Next6Hours; p != nil {
+			amount, period = p.Details.PrecipitationAmount, 6*time.Hour
+		}
+		if amount != nil && !s.Time.Before(covered) {
+			totals[len(totals)-1] += *amount
+			covered = s.Time.Add(period)
+
+			rate := Float32(float32(*amount / period.Hours()))
+			if day.PrecipIntensityMax == nil || *rate > *day.PrecipIntensityMax {
+				day.PrecipIntensityMax = rate
+				t := d.Time
+				day.PrecipIntensityMaxTime = &t
+			}
+		}
+
+		for _, v := range []struct{ to, from **float32 }{
+			{&day.PrecipProbability, &d.PrecipProbability},
+			{&day.WindSpeed, &d.WindSpeed},
+			{&day.WindGust, &d.WindGust},
+			{&day.UVIndex, &d.UVIndex},
+		} {
+			if *v.from != nil && (*v.to == nil || **v.from > **v.to) {
+				*v.to = *v.from
+			}
+		}
+
+		if iconRank(d.Icon) > iconRank(day.Icon) {
+			day.Icon, day.Summary, day.PrecipType = d.Icon, d.Summary, d.PrecipType
+		}
+	}
+
+	for i := range days {
+		days[i].PrecipIntensity = Float32(float32(totals[i] / 24))
+
+		// the whole day's weather is shown with a day icon
+		switch days[i].Icon {
+		case IconClearNight:
+			days[i].Icon = IconClearDay
+		case IconPartlyCloudyNight:
+			days[i].Icon = IconPartlyCloudyDay
+		}
+	}
+
+	return days
+}
+
+/*
+metIcon maps a MET Norway symbol code such as "lightrainshowers_day" onto a
+Darksky icon
+*/
+func metIcon(symbol string) Icon {
+	base, variant := symbol, ""
+	if i := strings.IndexByte(symbol, '_'); i >= 0 {
+		base, variant = symbol[:i], symbol[i+1:]
+	}
+	night := variant == "night"
+
+	switch {
+	case strings.Contains(base, "thunder"):
+		return IconThunderstorm
+	case strings.Contains(base, "sleet"):
+		return IconSleet
+	case strings.Contains(base, "snow"):
+		return IconSnow
+	case strings.Contains(base, "rain"):
+		return IconRain
+	case base == "fog":
+		return IconFog
+	case base == "cloudy":
+		return IconCloudy
+	case base == "partlycloudy":
+		if night {
+			return IconPartlyCloudyNight
+		}
+		return IconPartlyCloudyDay
+	case base == "clearsky" || base == "fair":
+		if night {
+			return IconClearNight
+		}
+		return IconClearDay
+	}
+	return ""
+}
+
+/*
+metWords are the words MET Norway symbol codes are made of, longest first
+where one begins another
+*/
+var metWords = []struct{ code, word string }{
+	{"clearsky", "Clear"},
+	{"fair", "Fair"},
+	{"partlycloudy", "Partly Cloudy"},
+	{"cloudy", "Cloudy"},
+	{"fog", "Fog"},
+	{"light", "Light"},
+	{"heavy", "Heavy"},
+	{"rain", "Rain"},
+	{"sleet", "Sleet"},
+	{"snow", "Snow"},
+	{"showers", "Showers"},
+	{"and", "and"},
+	{"thunder", "Thunder"},
+}
+
+/*
+metSummary spells out a symbol code, so "lightrainshowersandthunder_day"
+becomes "Light Rain Showers and Thunder"
+*/
+func metSummary(symbol string) string {
+	if i := strings.IndexByte(symbol, '_'); i >= 0 {
+		symbol = symbol[:i]
+	}
+
+	var words []string
+	for symbol != "" {
+		found := false
+		for _, w := range metWords {
+			if strings.HasPrefix(symbol, w.code) {
+				words = append(words, w.word)
+				symbol = symbol[len(w.code):]
+				found = true
+				break
+			}
+		}
+		if !found {
+			return ""
+		}
+	}
+
+	return strings.Join(words, " ")
+}
+
+func init() {
+	Register("metno", func(cfg ProviderConfig) (Provider, error) {
+		if cfg.UserAgent == "" {
+			return nil, errors.New("darksky: metno provider needs a user agent")
+		}
+
+		m := NewMETNorway(cfg.UserAgent)
+		m.Client = cfg.Client
+
+		if cfg.BaseURL != "" {
+			m.BaseURL = cfg.BaseURL
+		}
+
+		return m, nil
+	})
+}
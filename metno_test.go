@@ -0,0 +1,252 @@
+package darksky
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var (
+	locationforecastJSON = []byte(`
+	{"type":"Feature","geometry":{"type":"Point","coordinates":[10.75,59.91,12]},"properties":{"meta":{"updated_at":"2024-04-15T09:47:21Z","units":{"air_pressure_at_sea_level":"hPa","air_temperature":"celsius","cloud_area_fraction":"%","precipitation_amount":"mm","relative_humidity":"%","wind_from_direction":"degrees","wind_speed":"m/s"}},"timeseries":[
+	{"time":"2024-04-15T10:00:00Z","data":{"instant":{"details":{"air_pressure_at_sea_level":1012.3,"air_temperature":8.1,"cloud_area_fraction":12.5,"dew_point_temperature":1.9,"relative_humidity":64.6,"ultraviolet_index_clear_sky":2.1,"wind_from_direction":221.4,"wind_speed":3.2,"wind_speed_of_gust":7.4}},"next_12_hours":{"summary":{"symbol_code":"rain"},"details":{}},"next_1_hours":{"summary":{"symbol_code":"clearsky_day"},"details":{"precipitation_amount":0.0,"probability_of_precipitation":5}},"next_6_hours":{"summary":{"symbol_code":"rain"},"details":{"air_temperature_max":11.2,"air_temperature_min":7.9,"precipitation_amount":2.1,"probability_of_precipitation":60}}}},
+	{"time":"2024-04-15T11:00:00Z","data":{"instant":{"details":{"air_pressure_at_sea_level":1011.8,"air_temperature":9.0,"cloud_area_fraction":75.0,"dew_point_temperature":2.4,"relative_humidity":63.1,"ultraviolet_index_clear_sky":2.6,"wind_from_direction":225.0,"wind_speed":4.1,"wind_speed_of_gust":8.8}},"next_1_hours":{"summary":{"symbol_code":"lightrainshowers_day"},"details":{"precipitation_amount":0.4,"probability_of_precipitation":40}},"next_6_hours":{"summary":{"symbol_code":"rain"},"details":{"air_temperature_max":11.2,"air_temperature_min":7.9,"precipitation_amount":2.1,"probability_of_precipitation":60}}}},
+	{"time":"2024-04-15T12:00:00Z","data":{"instant":{"details":{"air_pressure_at_sea_level":1011.0,"air_temperature":9.6,"cloud_area_fraction":100.0,"dew_point_temperature":3.0,"relative_humidity":65.2,"ultraviolet_index_clear_sky":2.4,"wind_from_direction":230.2,"wind_speed":5.3,"wind_speed_of_gust":11.2}},"next_1_hours":{"summary":{"symbol_code":"rainandthunder"},"details":{"precipitation_amount":1.2,"probability_of_precipitation":80}},"next_6_hours":{"summary":{"symbol_code":"rain"},"details":{"air_temperature_max":11.2,"air_temperature_min":7.9,"precipitation_amount":2.1,"probability_of_precipitation":60}}}},
+	{"time":"2024-04-15T18:00:00Z","data":{"instant":{"details":{"air_pressure_at_sea_level":1009.4,"air_temperature":6.0,"cloud_area_fraction":100.0,"relative_humidity":88.0,"wind_from_direction":250.0,"wind_speed":6.0}},"next_6_hours":{"summary":{"symbol_code":"heavyrainandthunder"},"details":{"air_temperature_max":6.5,"air_temperature_min":3.9,"precipitation_amount":2.0,"probability_of_precipitation":90}}}},
+	{"time":"2024-04-16T00:00:00Z","data":{"instant":{"details":{"air_pressure_at_sea_level":1008.1,"air_temperature":3.5,"cloud_area_fraction":100.0,"relative_humidity":93.0,"wind_from_direction":270.0,"wind_speed":5.5}},"next_6_hours":{"summary":{"symbol_code":"snow"},"details":{"air_temperature_max":4.0,"air_temperature_min":2.2,"precipitation_amount":3.0,"probability_of_precipitation":85}}}},
+	{"time":"2024-04-16T06:00:00Z","data":{"instant":{"details":{"air_pressure_at_sea_level":1010.2,"air_temperature":2.8,"cloud_area_fraction":40.0,"relative_humidity":80.0,"wind_from_direction":290.0,"wind_speed":4.0}},"next_6_hours":{"summary":{"symbol_code":"partlycloudy_day"},"details":{"air_temperature_max":5.1,"air_temperature_min":2.5,"precipitation_amount":0.5,"probability_of_precipitation":20}}}}]}}
+	`)
+)
+
+func TestMETNorway(t *testing.T) {
+	var requests int
+	var agent, since, query string
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		agent, since, query = r.UserAgent(), r.Header.Get("If-Modified-Since"), r.URL.RawQuery
+
+		w.Header().Set("Last-Modified", "Mon, 15 Apr 2024 09:47:21 GMT")
+		w.Header().Set("Expires", "Mon, 15 Apr 2024 10:45:00 GMT")
+
+		if since == "Mon, 15 Apr 2024 09:47:21 GMT" {
+			w.Header().Set("Expires", "Mon, 15 Apr 2024 11:30:00 GMT")
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Write(locationforecastJSON)
+	}))
+	defer srv.Close()
+
+	p, err := NewProvider("metno", ProviderConfig{BaseURL: srv.URL, UserAgent: "example.com dev@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := p.(*METNorway)
+	now := time.Date(2024, 4, 15, 10, 30, 0, 0, time.UTC)
+	m.now = func() time.Time { return now }
+
+	res, err := p.Forecast(context.Background(), 59.91273, 10.74609, WithUnits(UnitsSI))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if agent != "example.com dev@example.com" || query != "lat=59.9127&lon=10.7461" {
+		t.Errorf("unexpected request %q %s", agent, query)
+	}
+
+	if res.Offset != 1 || res.Flags.Units != UnitsSI || res.Flags.Sources[0] != "met.no" {
+		t.Errorf("unexpected offset %v or flags %+v", res.Offset, res.Flags)
+	}
+
+	c := res.Currently
+	if *c.Temperature != 8.1 || !approx(*c.Humidity, 0.646) || !approx(*c.CloudCover, 0.125) || c.Icon != IconClearDay || c.Summary != "Clear" {
+		t.Errorf("unexpected current values %v %v %v %s %q", *c.Temperature, *c.Humidity, *c.CloudCover, c.Icon, c.Summary)
+	}
+
+	if _, offset := c.Time.Time().Zone(); offset != 3600 {
+		t.Errorf("expected the time in the estimated zone got %v", c.Time)
+	}
+
+	if len(res.Hourly.Data) != 3 {
+		t.Fatalf("expected the hourly part of the series got %d points", len(res.Hourly.Data))
+	}
+
+	h := res.Hourly.Data[1]
+	if h.Icon != IconRain || h.Summary != "Light Rain Showers" || *h.PrecipIntensity != 0.4 || !approx(*h.PrecipProbability, 0.4) {
+		t.Errorf("unexpected hour %s %q %v %v", h.Icon, h.Summary, *h.PrecipIntensity, *h.PrecipProbability)
+	}
+
+	if len(res.Daily.Data) != 2 {
+		t.Fatalf("expected 2 days got %d", len(res.Daily.Data))
+	}
+
+	d := res.Daily.Data[0]
+	if *d.TemperatureHigh != 11.2 || *d.TemperatureLow != 3.9 || !approx(*d.PrecipIntensity, 0.15) || !approx(*d.PrecipProbability, 0.9) {
+		t.Errorf("unexpected first day %v %v %v %v", *d.TemperatureHigh, *d.TemperatureLow, *d.PrecipIntensity, *d.PrecipProbability)
+	}
+
+	if d.Icon != IconThunderstorm || d.Summary != "Rain and Thunder" || !approx(*d.PrecipIntensityMax, 1.2) {
+		t.Errorf("unexpected first day conditions %s %q %v", d.Icon, d.Summary, *d.PrecipIntensityMax)
+	}
+
+	if local := d.Time.Time(); local.Hour() != 0 || local.Day() != 15 {
+		t.Errorf("expected the day to start at local midnight got %v", local)
+	}
+
+	d = res.Daily.Data[1]
+	if d.Icon != IconSnow || *d.TemperatureHigh != 5.1 || *d.TemperatureLow != 2.2 || !approx(*d.PrecipIntensity, 3.5/24) {
+		t.Errorf("unexpected second day %s %v %v %v", d.Icon, *d.TemperatureHigh, *d.TemperatureLow, *d.PrecipIntensity)
+	}
+
+	if _, err := p.Forecast(context.Background(), 59.91273, 10.74609); err != nil {
+		t.Fatal(err)
+	}
+
+	if requests != 1 {
+		t.Errorf("expected the forecast to be cached until it expires got %d requests", requests)
+	}
+
+	now = now.Add(30 * time.Minute)
+
+	res, err = p.Forecast(context.Background(), 59.91273, 10.74609)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if requests != 2 || since != "Mon, 15 Apr 2024 09:47:21 GMT" {
+		t.Errorf("expected a conditional request got %d requests since %q", requests, since)
+	}
+
+	if res.Currently == nil || res.Flags.Units != UnitsUS || !approx(*res.Currently.Temperature, 48.2) {
+		t.Errorf("expected the cached forecast after not modified got %s %v", res.Flags.Units, *res.Currently.Temperature)
+	}
+
+	if _, err := p.Forecast(context.Background(), 59.91273, 10.74609); err != nil {
+		t.Fatal(err)
+	}
+
+	if requests != 2 {
+		t.Errorf("expected the new expiry to be used got %d requests", requests)
+	}
+
+	if _, err := p.TimeMachine(context.Background(), 59.91, 10.75, now); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("expected time machine to be unsupported got %v", err)
+	}
+}
+
+func TestMETNorwayZone(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(locationforecastJSON)
+	}))
+	defer srv.Close()
+
+	oslo, err := time.LoadLocation("Europe/Oslo")
+	if err != nil {
+		t.Skip(err)
+	}
+
+	m := NewMETNorway("example.com dev@example.com")
+	m.BaseURL = srv.URL
+	m.Zone = oslo
+	m.now = func() time.Time { return time.Date(2024, 4, 15, 10, 30, 0, 0, time.UTC) }
+
+	res, err := m.Forecast(context.Background(), 59.91, 10.75)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Timezone != "Europe/Oslo" || res.Offset != 2 {
+		t.Errorf("expected the configured zone got %s %v", res.Timezone, res.Offset)
+	}
+
+	// 00:00Z is 02:00 in Oslo, so the 16th is still 2 days
+	if len(res.Daily.Data) != 2 || res.Daily.Data[1].Time.Time().Day() != 16 {
+		t.Errorf("unexpected days %+v", res.Daily.Data)
+	}
+}
+
+func TestMETNorwayCacheSize(t *testing.T) {
+	var revalidated []string
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-Modified-Since") != "" {
+			revalidated = append(revalidated, r.URL.RawQuery)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.Header().Set("Last-Modified", "Mon, 15 Apr 2024 09:47:21 GMT")
+		w.Header().Set("Expires", "Mon, 15 Apr 2024 10:45:00 GMT")
+		w.Write(locationforecastJSON)
+	}))
+	defer srv.Close()
+
+	now := time.Date(2024, 4, 15, 10, 30, 0, 0, time.UTC)
+
+	m := NewMETNorway("example.com dev@example.com")
+	m.BaseURL = srv.URL
+	m.CacheSize = 2
+	m.now = func() time.Time { return now }
+
+	for _, lat := range []float32{59.91, 60.39, 63.43} {
+		if _, err := m.Forecast(context.Background(), lat, 10.75); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(m.forecasts) != 2 {
+		t.Errorf("expected 2 cached forecasts got %d", len(m.forecasts))
+	}
+
+	now = now.Add(time.Hour)
+
+	for _, lat := range []float32{63.43, 59.91} {
+		if _, err := m.Forecast(context.Background(), lat, 10.75); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the first location was evicted, so it is fetched afresh
+	if len(revalidated) != 1 || revalidated[0] != "lat=63.43&lon=10.75" {
+		t.Errorf("expected only the kept forecast to be revalidated got %v", revalidated)
+	}
+}
+
+func TestMETNorwayNeedsUserAgent(t *testing.T) {
+	if _, err := NewProvider("metno", ProviderConfig{}); err == nil {
+		t.Errorf("expected an error without a user agent")
+	}
+}
+
+func TestMETIcon(t *testing.T) {
+	tests := []struct {
+		symbol  string
+		icon    Icon
+		summary string
+	}{
+		{"clearsky_night", IconClearNight, "Clear"},
+		{"fair_polartwilight", IconClearDay, "Fair"},
+		{"partlycloudy_night", IconPartlyCloudyNight, "Partly Cloudy"},
+		{"cloudy", IconCloudy, "Cloudy"},
+		{"fog", IconFog, "Fog"},
+		{"heavyrainshowers_day", IconRain, "Heavy Rain Showers"},
+		{"lightsleetshowersandthunder_night", IconThunderstorm, "Light Sleet Showers and Thunder"},
+		{"sleet", IconSleet, "Sleet"},
+		{"lightsnowshowers_day", IconSnow, "Light Snow Showers"},
+		{"hurricane", "", ""},
+	}
+
+	for _, test := range tests {
+		if icon := metIcon(test.symbol); icon != test.icon {
+			t.Errorf("%s: expected %s got %s", test.symbol, test.icon, icon)
+		}
+		if summary := metSummary(test.symbol); summary != test.summary {
+			t.Errorf("%s: expected %q got %q", test.symbol, test.summary, summary)
+		}
+	}
+}
@@ -0,0 +1,621 @@
+package darksky
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	nwsBaseURL = "https://api.weather.gov"
+
+	defaultNWSCacheSize = 64
+)
+
+/*
+NWS is a Provider backed by the US National Weather Service API. A forecast
+takes a points lookup, which is cached, followed by the gridpoint hourly and
+12 hour forecasts and the active alerts for the location. The API only covers
+the United States and has no history, so TimeMachine returns ErrUnsupported.
+The NWS rejects requests without a User-Agent.
+*/
+type NWS struct {
+	HTTPConfig
+	BaseURL string
+	// CacheSize bounds the locations whose points lookups are kept, evicting
+	// the least recently used. Zero uses a default of 64.
+	CacheSize int
+
+	mu     sync.Mutex
+	points map[string]*list.Element
+	order  *list.List
+	// now is replaced in tests
+	now func() time.Time
+}
+
+/*
+NewNWS constructs an NWS provider that identifies itself as userAgent. The
+NWS asks for a way to contact the application's author, such as a website or
+email address, to be included.
+*/
+func NewNWS(userAgent string) *NWS {
+	return &NWS{
+		HTTPConfig: HTTPConfig{Timeout: defaultTimeout, UserAgent: userAgent},
+		BaseURL:    nwsBaseURL,
+		now:        time.Now,
+	}
+}
+
+/*
+nwsPoint is the part of a points lookup the provider uses
+*/
+type nwsPoint struct {
+	key string
+
+	Forecast       string `json:"forecast"`
+	ForecastHourly string `json:"forecastHourly"`
+	TimeZone       string `json:"timeZone"`
+}
+
+type nwsPeriod struct {
+	StartTime                  time.Time `json:"startTime"`
+	EndTime                    time.Time `json:"endTime"`
+	IsDaytime                  bool      `json:"isDaytime"`
+	Temperature                nwsValue  `json:"temperature"`
+	TemperatureUnit            string    `json:"temperatureUnit"`
+	ProbabilityOfPrecipitation nwsValue  `json:"probabilityOfPrecipitation"`
+	Dewpoint                   nwsValue  `json:"dewpoint"`
+	RelativeHumidity           nwsValue  `json:"relativeHumidity"`
+	WindSpeed                  nwsValue  `json:"windSpeed"`
+	WindGust                   nwsValue  `json:"windGust"`
+	WindDirection              string    `json:"windDirection"`
+	Icon                       string    `json:"icon"`
+	ShortForecast              string    `json:"shortForecast"`
+}
+
+type nwsAlert struct {
+	ID          string     `json:"@id"`
+	AreaDesc    string     `json:"areaDesc"`
+	Effective   *time.Time `json:"effective"`
+	Onset       *time.Time `json:"onset"`
+	Expires     *time.Time `json:"expires"`
+	Ends        *time.Time `json:"ends"`
+	Severity    string     `json:"severity"`
+	Event       string     `json:"event"`
+	Headline    string     `json:"headline"`
+	Description string     `json:"description"`
+	Instruction string     `json:"instruction"`
+}
+
+/*
+nwsValue is an NWS quantitative value. Older fields send a bare number, with
+the unit in a sibling field, or a string such as "5 to 10 mph"; both decode
+into the same form.
+*/
+type nwsValue struct {
+	UnitCode string   `json:"unitCode"`
+	Value    *float64 `json:"value"`
+}
+
+/*
+UnmarshalJSON implements json.Unmarshaler
+*/
+func (v *nwsValue) UnmarshalJSON(b []byte) error {
+	switch {
+	case string(b) == "null":
+		return nil
+	case b[0] == '{':
+		type value nwsValue
+		return json.Unmarshal(b, (*value)(v))
+	case b[0] == '"':
+		var s string
+		if err := json.Unmarshal(b, &s); err != nil {
+			return err
+		}
+		*v = parseNWSSpeed(s)
+		return nil
+	}
+
+	var f float64
+	if err := json.Unmarshal(b, &f); err != nil {
+		return err
+	}
+	v.Value = &f
+	return nil
+}
+
+/*
+si returns the value in the package's SI units, or nil when it is missing or
+its unit is not understood
+*/
+func (v nwsValue) si() *float32 {
+	if v.Value == nil {
+		return nil
+	}
+
+	x := *v.Value
+	unit := v.UnitCode
+	if i := strings.LastIndex(unit, ":"); i >= 0 {
+		unit = unit[i+1:]
+	}
+
+	switch unit {
+	case "degC", "m_s-1", "mm", "km", "hPa", "degree_(angle)":
+	case "degF":
+		x = (x - 32) * 5 / 9
+	case "K":
+		x -= 273.15
+	case "percent":
+		x /= 100
+	case "km_h-1":
+		x /= 3.6
+	case "mi_h-1":
+		x *= 0.44704
+	case "kn":
+		x *= 0.514444
+	case "Pa":
+		x /= 100
+	case "m":
+		x /= 1000
+	default:
+		return nil
+	}
+
+	return Float32(float32(x))
+}
+
+/*
+parseNWSSpeed parses speeds such as "10 mph" and "5 to 10 mph", taking the
+highest speed of a range
+*/
+func parseNWSSpeed(s string) nwsValue {
+	fields := strings.Fields(s)
+	if len(fields) < 2 {
+		return nwsValue{}
+	}
+
+	units := map[string]string{
+		"mph":  "mi_h-1",
+		"km/h": "km_h-1",
+		"kt":   "kn",
+		"m/s":  "m_s-1",
+	}
+
+	v := nwsValue{UnitCode: units[fields[len(fields)-1]]}
+	for _, f := range fields[:len(fields)-1] {
+		if x, err := strconv.ParseFloat(f, 64); err == nil && (v.Value == nil || x > *v.Value) {
+			v.Value = &x
+		}
+	}
+
+	return v
+}
+
+var nwsCompass = map[string]float32{
+	"N": 0, "NNE": 22.5, "NE": 45, "ENE": 67.5,
+	"E": 90, "ESE": 112.5, "SE": 135, "SSE": 157.5,
+	"S": 180, "SSW": 202.5, "SW": 225, "WSW": 247.5,
+	"W": 270, "WNW": 292.5, "NW": 315, "NNW": 337.5,
+}
+
+/*
+Forecast implements Provider. NWS publishes no current observations with its
+forecast, so Currently is the forecast for the current hour.
+*/
+func (n *NWS) Forecast(ctx context.Context, lat, long float32, opts ...RequestOption) (Response, error) {
+	r := NewRequest(opts...)
+	if err := r.Validate(); err != nil {
+		return Response{}, err
+	}
+
+	point, err := n.point(ctx, lat, long)
+	if err != nil {
+		return Response{}, err
+	}
+
+	res := Response{
+		Latitude:  lat,
+		Longitude: long,
+		Timezone:  point.TimeZone,
+		Flags: Flags{
+			Sources: []string{"nws"},
+		},
+	}
+
+	loc := time.UTC
+	if l, err := loadLocation(point.TimeZone); err == nil {
+		loc = l
+	}
+	now := n.clock()
+	_, offset := now.In(loc).Zone()
+	res.Offset = float32(offset) / 3600
+
+	if !r.Excludes(BlockCurrently) || !r.Excludes(BlockHourly) {
+		periods, err := n.periods(ctx, point.ForecastHourly)
+		if err != nil {
+			return Response{}, err
+		}
+
+		hours := 49
+		if r.ExtendHourly {
+			hours = 169
+		}
+
+		data := make([]Data, len(periods))
+		for i, p := range periods {
+			data[i] = nwsData(p)
+		}
+		data = trimData(data, now.Truncate(time.Hour), hours)
+
+		if !r.Excludes(BlockCurrently) && len(data) > 0 {
+			current := data[0]
+			res.Currently = &current
+		}
+		if !r.Excludes(BlockHourly) {
+			res.Hourly = &DataSummary{Data: data}
+		}
+	}
+
+	if !r.Excludes(BlockDaily) {
+		periods, err := n.periods(ctx, point.Forecast)
+		if err != nil {
+			return Response{}, err
+		}
+		res.Daily = &DataSummary{Data: nwsDays(periods, loc)}
+	}
+
+	if !r.Excludes(BlockAlerts) {
+		alerts, err := n.alerts(ctx, lat, long)
+		if err != nil {
+			return Response{}, err
+		}
+		res.Alerts = alerts
+	}
+
+	if err := finishSI(&res, r); err != nil {
+		return Response{}, err
+	}
+
+	return res, nil
+}
+
+/*
+TimeMachine implements Provider. The NWS API has no history.
+*/
+func (n *NWS) TimeMachine(ctx context.Context, lat, long float32, t time.Time, opts ...RequestOption) (Response, error) {
+	return Response{}, ErrUnsupported
+}
+
+func (n *NWS) clock() time.Time {
+	if n.now != nil {
+		return n.now()
+	}
+	return time.Now()
+}
+
+func (n *NWS) get(ctx context.Context, u string, v interface{}) error {
+	header := http.Header{}
+	header.Set("Accept", "application/geo+json")
+
+	_, err := n.getJSON(ctx, u, header, "", v)
+	return err
+}
+
+/*
+point looks up the forecast URLs for a location. The NWS redirects requests
+with more than four decimal places, so coordinates are rounded to four and
+the lookup cached under them; grid assignments rarely change.
+*/
+func (n *NWS) point(ctx context.Context, lat, long float32) (nwsPoint, error) {
+	key := roundCoord(lat) + "," + roundCoord(long)
+
+	if p, ok := n.cached(key); ok {
+		return p, nil
+	}
+
+	var body struct {
+		Properties nwsPoint `json:"properties"`
+	}
+	if err := n.get(ctx, n.BaseURL+"/points/"+key, &body); err != nil {
+		return nwsPoint{}, err
+	}
+	p := body.Properties
+	p.key = key
+
+	n.cache(p)
+
+	return p, nil
+}
+
+/*
+cached returns the points lookup kept for key
+*/
+func (n *NWS) cached(key string) (nwsPoint, bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	el, ok := n.points[key]
+	if !ok {
+		return nwsPoint{}, false
+	}
+
+	n.order.MoveToFront(el)
+	return *el.Value.(*nwsPoint), true
+}
+
+/*
+cache keeps p, evicting the least recently used lookups past CacheSize
+*/
+func (n *NWS) cache(p nwsPoint) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.points == nil {
+		n.points = make(map[string]*list.Element)
+		n.order = list.New()
+	}
+
+	if el, ok := n.points[p.key]; ok {
+		*el.Value.(*nwsPoint) = p
+		n.order.MoveToFront(el)
+		return
+	}
+
+	n.points[p.key] = n.order.PushFront(&p)
+
+	size := n.CacheSize
+	if size <= 0 {
+		size = defaultNWSCacheSize
+	}
+
+	for n.order.Len() > size {
+		el := n.order.Back()
+		n.order.Remove(el)
+		delete(n.points, el.Value.(*nwsPoint).key)
+	}
+}
+
+func (n *NWS) periods(ctx context.Context, u string) ([]nwsPeriod, error) {
+	var body struct {
+		Properties struct {
+			Periods []nwsPeriod `json:"periods"`
+		} `json:"properties"`
+	}
+	if err := n.get(ctx, u, &body); err != nil {
+		return nil, err
+	}
+
+	periods := body.Properties.Periods
+	for i := range periods {
+		p := &periods[i]
+		if p.Temperature.UnitCode == "" {
+			p.Temperature.UnitCode = "deg" + p.TemperatureUnit
+		}
+	}
+
+	return periods, nil
+}
+
+func (n *NWS) alerts(ctx context.Context, lat, long float32) ([]Alert, error) {
+	var body struct {
+		Features []struct {
+			Properties nwsAlert `json:"properties"`
+		} `json:"features"`
+	}
+
+	q := url.Values{}
+	q.Set("point", roundCoord(lat)+","+roundCoord(long))
+	if err := n.get(ctx, n.BaseURL+"/alerts/active?"+q.Encode(), &body); err != nil {
+		return nil, err
+	}
+
+	alerts := make([]Alert, 0, len(body.Features))
+	for _, f := range body.Features {
+		alerts = append(alerts, nwsAlertOf(f.Properties))
+	}
+
+	return alerts, nil
+}
+
+/*
+nwsData converts a forecast period in SI units
+*/
+func nwsData(p nwsPeriod) Data {
+	d := Data{
+		Time:              UnixTime(p.StartTime),
+		Summary:           p.ShortForecast,
+		Temperature:       p.Temperature.si(),
+		DewPoint:          p.Dewpoint.si(),
+		Humidity:          p.RelativeHumidity.si(),
+		PrecipProbability: p.ProbabilityOfPrecipitation.si(),
+		WindSpeed:         p.WindSpeed.si(),
+		WindGust:          p.WindGust.si(),
+	}
+
+	if b, ok := nwsCompass[p.WindDirection]; ok {
+		d.WindBearing = Float32(b)
+	}
+
+	d.Icon = nwsIcon(p.Icon)
+	switch d.Icon {
+	case IconRain, IconThunderstorm:
+		d.PrecipType = PrecipRain
+	case IconSnow:
+		d.PrecipType = PrecipSnow
+	case IconSleet:
+		d.PrecipType = PrecipSleet
+	}
+
+	return d
+}
+
+/*
+nwsDays combines the day and night periods of the 12 hour forecast into one
+Data per local day, the day period giving the high and the night period the
+low
+*/
+func nwsDays(periods []nwsPeriod, loc *time.Location) []Data {
+	var days []Data
+
+	for _, p := range periods {
+		start := p.StartTime.In(loc)
+		if !p.IsDaytime && start.Hour() < 12 {
+			// an overnight period belongs to the evening before
+			start = start.AddDate(0, 0, -1)
+		}
+		midnight := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, loc)
+
+		if len(days) == 0 || !days[len(days)-1].Time.Time().Equal(midnight) {
+			days = append(days, Data{Time: UnixTime(midnight)})
+		}
+		day := &days[len(days)-1]
+
+		d := nwsData(p)
+		if p.IsDaytime {
+			day.TemperatureHigh, day.TemperatureMax = d.Temperature, d.Temperature
+		} else {
+			day.TemperatureLow, day.TemperatureMin = d.Temperature, d.Temperature
+		}
+
+		if p.IsDaytime || day.Icon == "" {
+			day.Icon, day.Summary, day.PrecipType = d.Icon, d.Summary, d.PrecipType
+			day.WindBearing = d.WindBearing
+		}
+
+		for _, v := range []struct{ to, from **float32 }{
+			{&day.PrecipProbability, &d.PrecipProbability},
+			{&day.WindSpeed, &d.WindSpeed},
+			{&day.WindGust, &d.WindGust},
+		} {
+			if *v.from != nil && (*v.to == nil || **v.from > **v.to) {
+				*v.to = *v.from
+			}
+		}
+	}
+
+	return days
+}
+
+var nwsIcons = map[string]Icon{
+	"skc": IconClearDay, "few": IconClearDay, "sct": IconPartlyCloudyDay,
+	"bkn": IconPartlyCloudyDay, "ovc": IconCloudy,
+	"wind_skc": IconWind, "wind_few": IconWind, "wind_sct": IconWind,
+	"wind_bkn": IconWind, "wind_ovc": IconWind,
+	"snow": IconSnow, "blizzard": IconSnow, "rain_snow": IconSleet,
+	"rain_sleet": IconSleet, "snow_sleet": IconSleet, "sleet": IconSleet,
+	"fzra": IconSleet, "rain_fzra": IconSleet, "snow_fzra": IconSleet,
+	"rain": IconRain, "rain_showers": IconRain, "rain_showers_hi": IconRain,
+	"tsra": IconThunderstorm, "tsra_sct": IconThunderstorm, "tsra_hi": IconThunderstorm,
+	"tornado": IconTornado, "hurricane": IconWind, "tropical_storm": IconWind,
+	"dust": IconFog, "smoke": IconFog, "haze": IconFog, "fog": IconFog,
+	"hot": IconClearDay, "cold": IconClearDay,
+}
+
+/*
+nwsIcon maps an NWS icon URL such as
+https://api.weather.gov/icons/land/night/rain_showers,40/tsra,60 onto a
+Darksky icon, using its first condition
+*/
+func nwsIcon(u string) Icon {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return ""
+	}
+
+	segments := strings.Split(strings.Trim(parsed.Path, "/"), "/")
+	for i := 0; i+1 < len(segments); i++ {
+		if segments[i] != "day" && segments[i] != "night" {
+			continue
+		}
+
+		condition := strings.SplitN(segments[i+1], ",", 2)[0]
+		icon := nwsIcons[condition]
+
+		if segments[i] == "night" {
+			switch icon {
+			case IconClearDay:
+				icon = IconClearNight
+			case IconPartlyCloudyDay:
+				icon = IconPartlyCloudyNight
+			}
+		}
+		return icon
+	}
+
+	return ""
+}
+
+/*
+nwsAlertOf converts an active alert, taking its severity from the kind of
+event when named like "Winter Storm Warning" and otherwise from the NWS
+severity
+*/
+func nwsAlertOf(a nwsAlert) Alert {
+	alert := Alert{
+		Title:       a.Headline,
+		Description: a.Description,
+		URI:         a.ID,
+	}
+
+	if alert.Title == "" {
+		alert.Title = a.Event
+	}
+
+	if a.Instruction != "" {
+		alert.Description += "\n\n" + a.Instruction
+	}
+
+	for _, r := range strings.Split(a.AreaDesc, ";") {
+		if r = strings.TrimSpace(r); r != "" {
+			alert.Regions = append(alert.Regions, r)
+		}
+	}
+
+	switch alert.Severity = eventSeverity(a.Event); {
+	case alert.Severity != "":
+	case a.Severity == "Extreme" || a.Severity == "Severe":
+		alert.Severity = SeverityWarning
+	case a.Severity == "Moderate":
+		alert.Severity = SeverityWatch
+	default:
+		alert.Severity = SeverityAdvisory
+	}
+
+	if t := a.Onset; t != nil {
+		alert.Time = UnixTime(*t)
+	} else if t := a.Effective; t != nil {
+		alert.Time = UnixTime(*t)
+	}
+
+	if t := a.Ends; t != nil {
+		alert.Expires = (*UnixTime)(t)
+	} else if t := a.Expires; t != nil {
+		alert.Expires = (*UnixTime)(t)
+	}
+
+	return alert
+}
+
+func init() {
+	Register("nws", func(cfg ProviderConfig) (Provider, error) {
+		if cfg.UserAgent == "" {
+			return nil, errors.New("darksky: nws provider needs a user agent")
+		}
+
+		n := NewNWS(cfg.UserAgent)
+		n.Client = cfg.Client
+
+		if cfg.BaseURL != "" {
+			n.BaseURL = cfg.BaseURL
+		}
+
+		return n, nil
+	})
+}
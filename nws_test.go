@@ -0,0 +1,241 @@
+package darksky
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	nwsPointsJSON = `
+	{"@context":[],"id":"https://api.weather.gov/points/39.7456,-97.0892","type":"Feature","geometry":{"type":"Point","coordinates":[-97.0892,39.7456]},"properties":{"@id":"https://api.weather.gov/points/39.7456,-97.0892","cwa":"TOP","gridId":"TOP","gridX":32,"gridY":81,"forecast":"{{base}}/gridpoints/TOP/32,81/forecast","forecastHourly":"{{base}}/gridpoints/TOP/32,81/forecast/hourly","forecastGridData":"{{base}}/gridpoints/TOP/32,81","observationStations":"{{base}}/gridpoints/TOP/32,81/stations","relativeLocation":{"type":"Feature","properties":{"city":"Linn","state":"KS"}},"timeZone":"America/Chicago","radarStation":"KTWX"}}
+	`
+	nwsHourlyJSON = `
+	{"type":"Feature","properties":{"units":"us","forecastGenerator":"HourlyForecastGenerator","generatedAt":"2024-04-15T16:05:12+00:00","periods":[
+	{"number":1,"name":"","startTime":"2024-04-15T11:00:00-05:00","endTime":"2024-04-15T12:00:00-05:00","isDaytime":true,"temperature":68,"temperatureUnit":"F","temperatureTrend":"","probabilityOfPrecipitation":{"unitCode":"wmoUnit:percent","value":3},"dewpoint":{"unitCode":"wmoUnit:degC","value":12.2},"relativeHumidity":{"unitCode":"wmoUnit:percent","value":68},"windSpeed":"15 mph","windDirection":"S","icon":"https://api.weather.gov/icons/land/day/bkn?size=small","shortForecast":"Mostly Cloudy","detailedForecast":""},
+	{"number":2,"name":"","startTime":"2024-04-15T12:00:00-05:00","endTime":"2024-04-15T13:00:00-05:00","isDaytime":true,"temperature":71,"temperatureUnit":"F","temperatureTrend":"","probabilityOfPrecipitation":{"unitCode":"wmoUnit:percent","value":20},"dewpoint":{"unitCode":"wmoUnit:degC","value":12.8},"relativeHumidity":{"unitCode":"wmoUnit:percent","value":62},"windSpeed":"15 to 20 mph","windDirection":"SSW","icon":"https://api.weather.gov/icons/land/day/tsra_hi,20?size=small","shortForecast":"Slight Chance Showers And Thunderstorms","detailedForecast":""},
+	{"number":3,"name":"","startTime":"2024-04-15T13:00:00-05:00","endTime":"2024-04-15T14:00:00-05:00","isDaytime":true,"temperature":{"unitCode":"wmoUnit:degC","value":22.8},"temperatureUnit":"F","temperatureTrend":"","probabilityOfPrecipitation":{"unitCode":"wmoUnit:percent","value":null},"dewpoint":{"unitCode":"wmoUnit:degC","value":13.3},"relativeHumidity":{"unitCode":"wmoUnit:percent","value":58},"windSpeed":{"unitCode":"wmoUnit:km_h-1","value":36},"windDirection":"SW","icon":"https://api.weather.gov/icons/land/day/sct?size=small","shortForecast":"Partly Sunny","detailedForecast":""}]}}
+	`
+	nwsForecastJSON = `
+	{"type":"Feature","properties":{"units":"us","forecastGenerator":"BaselineForecastGenerator","periods":[
+	{"number":1,"name":"This Afternoon","startTime":"2024-04-15T11:00:00-05:00","endTime":"2024-04-15T18:00:00-05:00","isDaytime":true,"temperature":78,"temperatureUnit":"F","probabilityOfPrecipitation":{"unitCode":"wmoUnit:percent","value":20},"windSpeed":"15 to 20 mph","windDirection":"S","icon":"https://api.weather.gov/icons/land/day/tsra_hi,20?size=medium","shortForecast":"Slight Chance Showers And Thunderstorms","detailedForecast":"A slight chance of showers and thunderstorms after 1pm."},
+	{"number":2,"name":"Tonight","startTime":"2024-04-15T18:00:00-05:00","endTime":"2024-04-16T06:00:00-05:00","isDaytime":false,"temperature":57,"temperatureUnit":"F","probabilityOfPrecipitation":{"unitCode":"wmoUnit:percent","value":70},"windSpeed":"20 to 25 mph","windDirection":"S","icon":"https://api.weather.gov/icons/land/night/tsra,70?size=medium","shortForecast":"Showers And Thunderstorms Likely","detailedForecast":"Showers and thunderstorms likely."},
+	{"number":3,"name":"Tuesday","startTime":"2024-04-16T06:00:00-05:00","endTime":"2024-04-16T18:00:00-05:00","isDaytime":true,"temperature":75,"temperatureUnit":"F","probabilityOfPrecipitation":{"unitCode":"wmoUnit:percent","value":null},"windSpeed":"15 mph","windDirection":"W","icon":"https://api.weather.gov/icons/land/day/few?size=medium","shortForecast":"Sunny","detailedForecast":"Sunny, with a high near 75."}]}}
+	`
+	nwsAlertsJSON = `
+	{"type":"FeatureCollection","features":[{"id":"https://api.weather.gov/alerts/urn:oid:2.49.0.1.840.0.1","type":"Feature","geometry":null,"properties":{"@id":"https://api.weather.gov/alerts/urn:oid:2.49.0.1.840.0.1","@type":"wx:Alert","id":"urn:oid:2.49.0.1.840.0.1","areaDesc":"Washington; Marshall; Nemaha","sent":"2024-04-15T10:02:00-05:00","effective":"2024-04-15T10:02:00-05:00","onset":"2024-04-15T13:00:00-05:00","expires":"2024-04-15T19:00:00-05:00","ends":"2024-04-15T21:00:00-05:00","status":"Actual","messageType":"Alert","category":"Met","severity":"Severe","certainty":"Possible","urgency":"Future","event":"Severe Thunderstorm Watch","sender":"w-nws.webmaster@noaa.gov","senderName":"NWS Topeka KS","headline":"Severe Thunderstorm Watch issued April 15 at 10:02AM CDT until April 15 at 9:00PM CDT by NWS Topeka KS","description":"Severe Thunderstorm Watch 123 remains valid until 9 PM CDT.","instruction":null,"response":"Monitor"}}],"title":"Current watches, warnings, and advisories for 39.7456 N, 97.0892 W"}
+	`
+)
+
+func TestNWS(t *testing.T) {
+	var points int
+	var agents []string
+	var alertQuery string
+
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		agents = append(agents, r.UserAgent())
+
+		var body string
+		switch r.URL.Path {
+		case "/points/39.7456,-97.0892":
+			points++
+			body = nwsPointsJSON
+		case "/gridpoints/TOP/32,81/forecast/hourly":
+			body = nwsHourlyJSON
+		case "/gridpoints/TOP/32,81/forecast":
+			body = nwsForecastJSON
+		case "/alerts/active":
+			alertQuery = r.URL.RawQuery
+			body = nwsAlertsJSON
+		default:
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/geo+json")
+		w.Write([]byte(strings.ReplaceAll(body, "{{base}}", srv.URL)))
+	}))
+	defer srv.Close()
+
+	p, err := NewProvider("nws", ProviderConfig{BaseURL: srv.URL, UserAgent: "(example.com, dev@example.com)"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n := p.(*NWS)
+	n.now = func() time.Time { return time.Date(2024, 4, 15, 16, 30, 0, 0, time.UTC) }
+
+	res, err := p.Forecast(context.Background(), 39.7456, -97.0892, WithUnits(UnitsSI))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	us, err := p.Forecast(context.Background(), 39.7456, -97.0892)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if points != 1 {
+		t.Errorf("expected the points lookup to be cached got %d lookups", points)
+	}
+
+	for _, ua := range agents {
+		if ua != "(example.com, dev@example.com)" {
+			t.Errorf("expected the configured user agent got %q", ua)
+		}
+	}
+
+	if alertQuery != "point=39.7456%2C-97.0892" {
+		t.Errorf("unexpected alerts query %s", alertQuery)
+	}
+
+	if res.Timezone != "America/Chicago" || res.Offset != -5 || res.Flags.Units != UnitsSI {
+		t.Errorf("unexpected zone %s %v or units %s", res.Timezone, res.Offset, res.Flags.Units)
+	}
+
+	c := res.Currently
+	if !approx(*c.Temperature, 20) || !approx(*c.Humidity, 0.68) || *c.DewPoint != 12.2 || c.Icon != IconPartlyCloudyDay {
+		t.Errorf("unexpected current values %v %v %v %s", *c.Temperature, *c.Humidity, *c.DewPoint, c.Icon)
+	}
+
+	if !approx(*c.WindSpeed, 6.7056) || *c.WindBearing != 180 {
+		t.Errorf("unexpected wind %v %v", *c.WindSpeed, *c.WindBearing)
+	}
+
+	if _, offset := c.Time.Time().Zone(); offset != -5*3600 {
+		t.Errorf("expected the time in the Chicago zone got %v", c.Time)
+	}
+
+	if len(res.Hourly.Data) != 3 {
+		t.Fatalf("expected 3 hours got %d", len(res.Hourly.Data))
+	}
+
+	h := res.Hourly.Data[1]
+	if h.Icon != IconThunderstorm || h.PrecipType != PrecipRain || !approx(*h.WindSpeed, 8.9408) || *h.WindBearing != 202.5 {
+		t.Errorf("unexpected hour %s %s %v %v", h.Icon, h.PrecipType, *h.WindSpeed, *h.WindBearing)
+	}
+
+	h = res.Hourly.Data[2]
+	if *h.Temperature != 22.8 || !approx(*h.WindSpeed, 10) || h.PrecipProbability != nil {
+		t.Errorf("expected quantitative values to convert got %v %v %v", *h.Temperature, *h.WindSpeed, h.PrecipProbability)
+	}
+
+	if len(res.Daily.Data) != 2 {
+		t.Fatalf("expected periods combined into 2 days got %d", len(res.Daily.Data))
+	}
+
+	d := res.Daily.Data[0]
+	if !approx(*d.TemperatureHigh, 25.556) || !approx(*d.TemperatureLow, 13.889) || !approx(*d.PrecipProbability, 0.7) || d.Summary != "Slight Chance Showers And Thunderstorms" {
+		t.Errorf("unexpected day %v %v %v %q", *d.TemperatureHigh, *d.TemperatureLow, *d.PrecipProbability, d.Summary)
+	}
+
+	if local := d.Time.Time(); local.Hour() != 0 || local.Day() != 15 {
+		t.Errorf("expected the day to start at local midnight got %v", local)
+	}
+
+	if d = res.Daily.Data[1]; d.Icon != IconClearDay || d.TemperatureLow != nil {
+		t.Errorf("unexpected second day %s %v", d.Icon, d.TemperatureLow)
+	}
+
+	// the current conditions and the day's high and max share their values
+	if u := us.Currently; us.Flags.Units != UnitsUS || !approx(*u.Temperature, 68) || !approx(*us.Hourly.Data[0].Temperature, 68) {
+		t.Errorf("expected 68F in US units got %v %v", *u.Temperature, us.Flags.Units)
+	}
+
+	if d := us.Daily.Data[0]; !approx(*d.TemperatureHigh, 78) || !approx(*d.TemperatureMax, 78) {
+		t.Errorf("expected a 78F high got %v and %v", *d.TemperatureHigh, *d.TemperatureMax)
+	}
+
+	if len(res.Alerts) != 1 {
+		t.Fatalf("expected 1 alert got %d", len(res.Alerts))
+	}
+
+	a := res.Alerts[0]
+	if a.Severity != SeverityWatch || len(a.Regions) != 3 || a.Regions[2] != "Nemaha" {
+		t.Errorf("unexpected alert %s %v", a.Severity, a.Regions)
+	}
+
+	if a.Time.Time().Unix() != 1713204000 || a.Expires.Time().Unix() != 1713232800 {
+		t.Errorf("expected the alert from onset to ends got %v %v", a.Time, a.Expires)
+	}
+
+	if _, err := p.TimeMachine(context.Background(), 39.7456, -97.0892, time.Now()); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("expected time machine to be unsupported got %v", err)
+	}
+}
+
+func TestNWSCacheSize(t *testing.T) {
+	var lookups []string
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lookups = append(lookups, strings.TrimPrefix(r.URL.Path, "/points/"))
+		w.Write([]byte(nwsPointsJSON))
+	}))
+	defer srv.Close()
+
+	n := NewNWS("(example.com, dev@example.com)")
+	n.BaseURL = srv.URL
+	n.CacheSize = 2
+
+	for _, lat := range []float32{39.7456, 38.9, 37.7, 37.7, 39.7456} {
+		if _, err := n.point(context.Background(), lat, -97.0892); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(n.points) != 2 {
+		t.Errorf("expected 2 cached points got %d", len(n.points))
+	}
+
+	// the first location was evicted, so it is looked up again
+	if len(lookups) != 4 || lookups[3] != "39.7456,-97.0892" {
+		t.Errorf("unexpected lookups %v", lookups)
+	}
+}
+
+func TestNWSNotFound(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/problem+json")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"correlationId":"1a2b","title":"Data Unavailable For Requested Point","type":"https://api.weather.gov/problems/InvalidPoint","status":404,"detail":"Unable to provide data for requested point 51.5,-0.12","instance":"https://api.weather.gov/requests/1a2b"}`))
+	}))
+	defer srv.Close()
+
+	n := NewNWS("(example.com, dev@example.com)")
+	n.BaseURL = srv.URL
+
+	_, err := n.Forecast(context.Background(), 51.5, -0.12)
+	if !errors.Is(err, ErrNotFound) || !strings.Contains(err.Error(), "Unable to provide data") {
+		t.Errorf("expected not found with the detail got %v", err)
+	}
+}
+
+func TestNWSNeedsUserAgent(t *testing.T) {
+	if _, err := NewProvider("nws", ProviderConfig{}); err == nil {
+		t.Errorf("expected an error without a user agent")
+	}
+}
+
+func TestNWSIcon(t *testing.T) {
+	tests := map[string]Icon{
+		"https://api.weather.gov/icons/land/day/skc?size=small":            IconClearDay,
+		"https://api.weather.gov/icons/land/night/few?size=small":          IconClearNight,
+		"https://api.weather.gov/icons/land/night/sct":                     IconPartlyCloudyNight,
+		"https://api.weather.gov/icons/land/night/rain_showers,40/tsra,60": IconRain,
+		"https://api.weather.gov/icons/land/day/snow_fzra,50?size=medium":  IconSleet,
+		"https://api.weather.gov/icons/land/day/wind_ovc":                  IconWind,
+		"https://api.weather.gov/icons/land/day/unknown":                   "",
+		"": "",
+	}
+
+	for u, icon := range tests {
+		if got := nwsIcon(u); got != icon {
+			t.Errorf("%s: expected %s got %s", u, icon, got)
+		}
+	}
+}
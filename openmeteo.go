@@ -0,0 +1,588 @@
+package darksky
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	openMeteoBaseURL    = "https://api.open-meteo.com/v1/forecast"
+	openMeteoArchiveURL = "https://archive-api.open-meteo.com/v1/archive"
+
+	// openMeteoArchiveAge is how old a Time Machine request must be before
+	// it is sent to the archive rather than the forecast API's past days
+	openMeteoArchiveAge = 90 * 24 * time.Hour
+)
+
+/*
+Variables requested from Open-Meteo. The archive does not offer every
+forecast variable and rejects requests for the missing ones.
+*/
+var (
+	openMeteoCurrent = []string{
+		"temperature_2m", "relative_humidity_2m", "dew_point_2m", "apparent_temperature",
+		"precipitation", "rain", "snowfall", "weather_code", "cloud_cover", "pressure_msl",
+		"visibility", "wind_speed_10m", "wind_direction_10m", "wind_gusts_10m", "uv_index", "is_day",
+	}
+	openMeteoHourly = append(openMeteoCurrent, "precipitation_probability")
+	openMeteoDaily  = []string{
+		"weather_code", "temperature_2m_max", "temperature_2m_min", "apparent_temperature_max",
+		"apparent_temperature_min", "sunrise", "sunset", "uv_index_max", "precipitation_sum",
+		"rain_sum", "snowfall_sum", "precipitation_probability_max", "wind_speed_10m_max",
+		"wind_gusts_10m_max", "wind_direction_10m_dominant",
+	}
+	openMeteoArchiveHourly = []string{
+		"temperature_2m", "relative_humidity_2m", "dew_point_2m", "apparent_temperature",
+		"precipitation", "rain", "snowfall", "weather_code", "cloud_cover", "pressure_msl",
+		"wind_speed_10m", "wind_direction_10m", "wind_gusts_10m", "is_day",
+	}
+	openMeteoArchiveDaily = []string{
+		"weather_code", "temperature_2m_max", "temperature_2m_min", "apparent_temperature_max",
+		"apparent_temperature_min", "sunrise", "sunset", "precipitation_sum", "rain_sum",
+		"snowfall_sum", "wind_speed_10m_max", "wind_gusts_10m_max", "wind_direction_10m_dominant",
+	}
+)
+
+/*
+OpenMeteo is a keyless Provider backed by Open-Meteo. Its column-oriented
+hourly and daily arrays are transposed into Data, and WMO weather codes are
+mapped onto Darksky icons and summaries.
+*/
+type OpenMeteo struct {
+	HTTPConfig
+	BaseURL    string
+	ArchiveURL string
+	// now is replaced in tests
+	now func() time.Time
+}
+
+/*
+NewOpenMeteo constructs an OpenMeteo provider for the public endpoints
+*/
+func NewOpenMeteo() *OpenMeteo {
+	return &OpenMeteo{
+		HTTPConfig: HTTPConfig{Timeout: defaultTimeout},
+		BaseURL:    openMeteoBaseURL,
+		ArchiveURL: openMeteoArchiveURL,
+		now:        time.Now,
+	}
+}
+
+/*
+openMeteoResponse is the part of an Open-Meteo response the provider reads
+*/
+type openMeteoResponse struct {
+	Latitude         float32             `json:"latitude"`
+	Longitude        float32             `json:"longitude"`
+	Timezone         string              `json:"timezone"`
+	UTCOffsetSeconds int                 `json:"utc_offset_seconds"`
+	Current          map[string]*float64 `json:"current"`
+	Hourly           openMeteoColumns    `json:"hourly"`
+	Daily            openMeteoColumns    `json:"daily"`
+}
+
+/*
+openMeteoColumns holds one array per variable, all indexed like "time"
+*/
+type openMeteoColumns map[string][]*float64
+
+func (c openMeteoColumns) len() int {
+	return len(c["time"])
+}
+
+func (c openMeteoColumns) at(name string, i int) *float64 {
+	col := c[name]
+	if i >= len(col) {
+		return nil
+	}
+	return col[i]
+}
+
+func (c openMeteoColumns) row(i int) map[string]*float64 {
+	row := make(map[string]*float64, len(c))
+	for name := range c {
+		row[name] = c.at(name, i)
+	}
+	return row
+}
+
+/*
+Forecast implements Provider
+*/
+func (o *OpenMeteo) Forecast(ctx context.Context, lat, long float32, opts ...RequestOption) (Response, error) {
+	r := NewRequest(opts...)
+	if err := r.Validate(); err != nil {
+		return Response{}, err
+	}
+
+	q := o.query(lat, long)
+	if !r.Excludes(BlockCurrently) {
+		q.Set("current", strings.Join(openMeteoCurrent, ","))
+	}
+	if !r.Excludes(BlockHourly) {
+		q.Set("hourly", strings.Join(openMeteoHourly, ","))
+	}
+	if !r.Excludes(BlockDaily) {
+		q.Set("daily", strings.Join(openMeteoDaily, ","))
+	}
+	q.Set("forecast_days", "8")
+
+	hours := 49
+	if r.ExtendHourly {
+		hours = 169
+	}
+
+	return o.get(ctx, o.BaseURL, q, r, func(res *Response, om *openMeteoResponse) {
+		if om.Current != nil {
+			res.Currently = openMeteoData(om.Current, true)
+		}
+
+		var from time.Time
+		if res.Currently != nil {
+			from = res.Currently.Time.Time().Truncate(time.Hour)
+		} else {
+			from = o.clock().Truncate(time.Hour)
+		}
+
+		if res.Hourly != nil {
+			res.Hourly.Data = trimData(res.Hourly.Data, from, hours)
+		}
+	})
+}
+
+/*
+TimeMachine implements Provider, returning the local day containing t with
+the hour containing t as the current conditions. Requests older than 90 days
+are answered from the Open-Meteo archive.
+*/
+func (o *OpenMeteo) TimeMachine(ctx context.Context, lat, long float32, t time.Time, opts ...RequestOption) (Response, error) {
+	r := NewRequest(append(append([]RequestOption(nil), opts...), At(t))...)
+	if err := r.Validate(); err != nil {
+		return Response{}, err
+	}
+
+	base, hourly, daily := o.BaseURL, openMeteoHourly, openMeteoDaily
+	if o.clock().Sub(t) > openMeteoArchiveAge {
+		base, hourly, daily = o.ArchiveURL, openMeteoArchiveHourly, openMeteoArchiveDaily
+	}
+
+	// the location's zone is only known from the answer, so the days either
+	// side of t's UTC date are asked for and trimmed to the local day
+	day := t.UTC()
+
+	q := o.query(lat, long)
+	q.Set("hourly", strings.Join(hourly, ","))
+	if !r.Excludes(BlockDaily) {
+		q.Set("daily", strings.Join(daily, ","))
+	}
+	q.Set("start_date", day.AddDate(0, 0, -1).Format("2006-01-02"))
+	q.Set("end_date", day.AddDate(0, 0, 1).Format("2006-01-02"))
+
+	return o.get(ctx, base, q, r, func(res *Response, om *openMeteoResponse) {
+		loc := res.Location()
+		y, m, d := t.In(loc).Date()
+		from := time.Date(y, m, d, 0, 0, 0, 0, loc)
+		to := from.AddDate(0, 0, 1)
+
+		if res.Daily != nil {
+			res.Daily.Data = dataBetween(res.Daily.Data, from, to)
+		}
+
+		if res.Hourly == nil {
+			return
+		}
+		res.Hourly.Data = dataBetween(res.Hourly.Data, from, to)
+
+		if !r.Excludes(BlockCurrently) {
+			for i := range res.Hourly.Data {
+				if h := res.Hourly.Data[i].Time.Time(); !t.Before(h) && t.Before(h.Add(time.Hour)) {
+					current := res.Hourly.Data[i]
+					res.Currently = &current
+					break
+				}
+			}
+		}
+
+		if r.Excludes(BlockHourly) {
+			res.Hourly = nil
+		}
+	})
+}
+
+func (o *OpenMeteo) clock() time.Time {
+	if o.now != nil {
+		return o.now()
+	}
+	return time.Now()
+}
+
+func (o *OpenMeteo) query(lat, long float32) url.Values {
+	q := url.Values{}
+	q.Set("latitude", formatCoord(lat))
+	q.Set("longitude", formatCoord(long))
+	q.Set("timezone", "auto")
+	q.Set("timeformat", "unixtime")
+	q.Set("temperature_unit", "celsius")
+	q.Set("wind_speed_unit", "ms")
+	q.Set("precipitation_unit", "mm")
+	return q
+}
+
+/*
+get requests base with q and converts the answer into a Response, letting
+finish adjust it while it is still in SI units
+*/
+func (o *OpenMeteo) get(ctx context.Context, base string, q url.Values, r Request, finish func(*Response, *openMeteoResponse)) (Response, error) {
+	om := openMeteoResponse{}
+
+	if _, err := o.getJSON(ctx, base+"?"+q.Encode(), nil, "", &om); err != nil {
+		return Response{}, err
+	}
+
+	res := Response{
+		Latitude:  om.Latitude,
+		Longitude: om.Longitude,
+		Timezone:  om.Timezone,
+		Offset:    float32(om.UTCOffsetSeconds) / 3600,
+		Flags: Flags{
+			Sources: []string{"open-meteo"},
+		},
+	}
+
+	if n := om.Hourly.len(); n > 0 {
+		res.Hourly = &DataSummary{Data: make([]Data, n)}
+		for i := 0; i < n; i++ {
+			res.Hourly.Data[i] = *openMeteoData(om.Hourly.row(i), false)
+			scale(res.Hourly.Data[i].Visibility, 0.001)
+		}
+	}
+
+	if n := om.Daily.len(); n > 0 {
+		res.Daily = &DataSummary{Data: make([]Data, n)}
+		for i := 0; i < n; i++ {
+			res.Daily.Data[i] = openMeteoDay(om.Daily.row(i))
+		}
+	}
+
+	finish(&res, &om)
+
+	if res.Currently != nil && om.Current != nil {
+		scale(res.Currently.Visibility, 0.001)
+	}
+
+	if err := finishSI(&res, r); err != nil {
+		return Response{}, err
+	}
+
+	return res, nil
+}
+
+/*
+openMeteoData converts one current or hourly row in SI units, except for
+visibility which is left in meters
+*/
+func openMeteoData(row map[string]*float64, current bool) *Data {
+	d := &Data{
+		Temperature:         optional(row["temperature_2m"]),
+		ApparentTemperature: optional(row["apparent_temperature"]),
+		DewPoint:            optional(row["dew_point_2m"]),
+		Humidity:            percent(row["relative_humidity_2m"]),
+		Pressure:            optional(row["pressure_msl"]),
+		WindSpeed:           optional(row["wind_speed_10m"]),
+		WindGust:            optional(row["wind_gusts_10m"]),
+		WindBearing:         optional(row["wind_direction_10m"]),
+		CloudCover:          percent(row["cloud_cover"]),
+		UVIndex:             optional(row["uv_index"]),
+		Visibility:          optional(row["visibility"]),
+		PrecipProbability:   percent(row["precipitation_probability"]),
+	}
+
+	if t := row["time"]; t != nil {
+		d.Time = UnixTime(time.Unix(int64(*t), 0))
+	}
+
+	if p := row["precipitation"]; p != nil {
+		// precipitation is summed over the preceding interval, an hour for
+		// hourly data
+		rate := *p
+		if iv := row["interval"]; current && iv != nil && *iv > 0 {
+			rate *= 3600 / *iv
+		}
+		d.PrecipIntensity = Float32(float32(rate))
+	}
+
+	code := -1
+	if c := row["weather_code"]; c != nil {
+		code = int(*c)
+	}
+	day := row["is_day"] == nil || *row["is_day"] != 0
+
+	d.Icon, d.Summary = wmoIcon(code, day), wmoSummary(code)
+	d.PrecipType = precipType(row["snowfall"], row["rain"], code)
+
+	return d
+}
+
+/*
+openMeteoDay converts one daily row in SI units
+*/
+func openMeteoDay(row map[string]*float64) Data {
+	d := Data{
+		TemperatureHigh:         optional(row["temperature_2m_max"]),
+		TemperatureMax:          optional(row["temperature_2m_max"]),
+		TemperatureLow:          optional(row["temperature_2m_min"]),
+		TemperatureMin:          optional(row["temperature_2m_min"]),
+		ApparentTemperatureHigh: optional(row["apparent_temperature_max"]),
+		ApparentTemperatureMax:  optional(row["apparent_temperature_max"]),
+		ApparentTemperatureLow:  optional(row["apparent_temperature_min"]),
+		ApparentTemperatureMin:  optional(row["apparent_temperature_min"]),
+		SunriseTime:             unixTime(row["sunrise"]),
+		SunsetTime:              unixTime(row["sunset"]),
+		UVIndex:                 optional(row["uv_index_max"]),
+		PrecipProbability:       percent(row["precipitation_probability_max"]),
+		WindSpeed:               optional(row["wind_speed_10m_max"]),
+		WindGust:                optional(row["wind_gusts_10m_max"]),
+		WindBearing:             optional(row["wind_direction_10m_dominant"]),
+	}
+
+	if t := row["time"]; t != nil {
+		d.Time = UnixTime(time.Unix(int64(*t), 0))
+	}
+
+	if p := row["precipitation_sum"]; p != nil {
+		d.PrecipIntensity = Float32(float32(*p / 24))
+	}
+
+	if s := row["snowfall_sum"]; s != nil && *s > 0 {
+		d.PrecipAccumulation = Float32(float32(*s))
+	}
+
+	code := -1
+	if c := row["weather_code"]; c != nil {
+		code = int(*c)
+	}
+
+	d.Icon, d.Summary = wmoIcon(code, true), wmoSummary(code)
+	d.PrecipType = precipType(row["snowfall_sum"], row["rain_sum"], code)
+
+	return d
+}
+
+/*
+trimData returns up to n points starting with the one at or after from
+*/
+func trimData(data []Data, from time.Time, n int) []Data {
+	i := 0
+	for i < len(data) && data[i].Time.Time().Before(from) {
+		i++
+	}
+
+	data = data[i:]
+	if len(data) > n {
+		data = data[:n]
+	}
+	return data
+}
+
+/*
+dataBetween returns the points at or after from and before to
+*/
+func dataBetween(data []Data, from, to time.Time) []Data {
+	var ret []Data
+	for _, d := range data {
+		if t := d.Time.Time(); !t.Before(from) && t.Before(to) {
+			ret = append(ret, d)
+		}
+	}
+	return ret
+}
+
+/*
+summarize picks the icon and summary of the most severe point of a block
+*/
+func summarize(data []Data) (Icon, string) {
+	var icon Icon
+	var summary string
+	rank := -1
+
+	for _, d := range data {
+		if r := iconRank(d.Icon); r > rank {
+			icon, summary, rank = d.Icon, d.Summary, r
+		}
+	}
+
+	return icon, summary
+}
+
+func iconRank(i Icon) int {
+	switch i.Category() {
+	case CategorySevere:
+		return 5
+	case CategoryPrecipitation:
+		return 4
+	case CategoryFog, CategoryWind:
+		return 3
+	case CategoryCloudy:
+		if i == IconCloudy {
+			return 2
+		}
+		return 1
+	case CategoryClear:
+		return 0
+	}
+	return -1
+}
+
+/*
+wmoIcon maps a WMO weather interpretation code onto a Darksky icon
+*/
+func wmoIcon(code int, day bool) Icon {
+	switch {
+	case code == 0 || code == 1:
+		if day {
+			return IconClearDay
+		}
+		return IconClearNight
+	case code == 2:
+		if day {
+			return IconPartlyCloudyDay
+		}
+		return IconPartlyCloudyNight
+	case code == 3:
+		return IconCloudy
+	case code == 45 || code == 48:
+		return IconFog
+	case code == 56 || code == 57 || code == 66 || code == 67:
+		return IconSleet
+	case code >= 51 && code <= 65, code >= 80 && code <= 82:
+		return IconRain
+	case code >= 71 && code <= 77, code == 85 || code == 86:
+		return IconSnow
+	case code >= 95 && code <= 99:
+		return IconThunderstorm
+	}
+	return ""
+}
+
+var wmoSummaries = map[int]string{
+	0:  "Clear",
+	1:  "Mostly Clear",
+	2:  "Partly Cloudy",
+	3:  "Overcast",
+	45: "Foggy",
+	48: "Depositing Rime Fog",
+	51: "Light Drizzle",
+	53: "Drizzle",
+	55: "Heavy Drizzle",
+	56: "Light Freezing Drizzle",
+	57: "Freezing Drizzle",
+	61: "Light Rain",
+	63: "Rain",
+	65: "Heavy Rain",
+	66: "Light Freezing Rain",
+	67: "Freezing Rain",
+	71: "Light Snow",
+	73: "Snow",
+	75: "Heavy Snow",
+	77: "Snow Grains",
+	80: "Light Rain Showers",
+	81: "Rain Showers",
+	82: "Heavy Rain Showers",
+	85: "Light Snow Showers",
+	86: "Heavy Snow Showers",
+	95: "Thunderstorms",
+	96: "Thunderstorms with Hail",
+	99: "Thunderstorms with Heavy Hail",
+}
+
+/*
+wmoSummary describes a WMO weather interpretation code
+*/
+func wmoSummary(code int) string {
+	if s, ok := wmoSummaries[code]; ok {
+		return s
+	}
+	if code < 0 {
+		return ""
+	}
+	return "Weather code " + strconv.Itoa(code)
+}
+
+/*
+precipType decides the kind of precipitation from snow and rain amounts,
+falling back to the weather code
+*/
+func precipType(snow, rain *float64, code int) PrecipType {
+	switch {
+	case snow != nil && *snow > 0:
+		return PrecipSnow
+	case rain != nil && *rain > 0:
+		return PrecipRain
+	}
+
+	switch wmoIcon(code, true) {
+	case IconSleet:
+		return PrecipSleet
+	case IconSnow:
+		return PrecipSnow
+	case IconRain, IconThunderstorm:
+		return PrecipRain
+	}
+	return ""
+}
+
+/*
+optional, percent and unixTime convert nullable provider values into optional
+Data fields
+*/
+func optional(v *float64) *float32 {
+	if v == nil {
+		return nil
+	}
+	return Float32(float32(*v))
+}
+
+func percent(v *float64) *float32 {
+	if v == nil {
+		return nil
+	}
+	return Float32(float32(*v / 100))
+}
+
+func unixTime(v *float64) *UnixTime {
+	if v == nil {
+		return nil
+	}
+	t := UnixTime(time.Unix(int64(*v), 0))
+	return &t
+}
+
+/*
+scale multiplies an optional value in place
+*/
+func scale(v *float32, by float64) {
+	if v != nil {
+		*v = float32(float64(*v) * by)
+	}
+}
+
+func init() {
+	Register("openmeteo", func(cfg ProviderConfig) (Provider, error) {
+		o := NewOpenMeteo()
+		o.Client = cfg.Client
+		o.UserAgent = cfg.UserAgent
+
+		if cfg.BaseURL != "" {
+			o.BaseURL = cfg.BaseURL
+		}
+
+		if u := cfg.Options["archive_url"]; u != "" {
+			o.ArchiveURL = u
+		}
+
+		return o, nil
+	})
+}
@@ -0,0 +1,264 @@
+package darksky
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	openMeteoJSON = []byte(`
+	{"latitude":52.52,"longitude":13.419998,"generationtime_ms":0.123,"utc_offset_seconds":7200,"timezone":"Europe/Berlin","timezone_abbreviation":"CEST","elevation":38.0,
+	"current_units":{"time":"unixtime","interval":"seconds","temperature_2m":"°C"},
+	"current":{"time":1713200400,"interval":900,"temperature_2m":14.2,"relative_humidity_2m":71,"dew_point_2m":9.0,"apparent_temperature":12.8,"precipitation":0.3,"rain":0.3,"snowfall":0.0,"weather_code":61,"cloud_cover":100,"pressure_msl":1008.4,"visibility":24140.0,"wind_speed_10m":4.2,"wind_direction_10m":250,"wind_gusts_10m":9.1,"uv_index":1.2,"is_day":1},
+	"hourly_units":{"time":"unixtime","temperature_2m":"°C"},
+	"hourly":{"time":[1713196800,1713200400,1713204000],"temperature_2m":[14.6,14.2,13.1],"relative_humidity_2m":[68,71,80],"dew_point_2m":[8.8,9.0,9.7],"apparent_temperature":[13.3,12.8,11.9],"precipitation":[0.0,1.2,3.4],"rain":[0.0,1.2,3.4],"snowfall":[0.0,0.0,0.0],"weather_code":[3,61,95],"cloud_cover":[96,100,100],"pressure_msl":[1008.9,1008.4,1007.7],"visibility":[24140.0,18000.0,null],"wind_speed_10m":[4.0,4.2,6.3],"wind_direction_10m":[245,250,262],"wind_gusts_10m":[8.6,9.1,15.2],"uv_index":[1.9,1.2,0.5],"is_day":[1,1,1],"precipitation_probability":[20,65,90]},
+	"daily_units":{"time":"unixtime"},
+	"daily":{"time":[1713132000],"weather_code":[63],"temperature_2m_max":[15.1],"temperature_2m_min":[7.4],"apparent_temperature_max":[13.9],"apparent_temperature_min":[5.2],"sunrise":[1713153873],"sunset":[1713204713],"uv_index_max":[3.55],"precipitation_sum":[7.2],"rain_sum":[7.2],"snowfall_sum":[0.0],"precipitation_probability_max":[90],"wind_speed_10m_max":[6.3],"wind_gusts_10m_max":[15.2],"wind_direction_10m_dominant":[251]}}
+	`)
+)
+
+func TestOpenMeteo(t *testing.T) {
+	var query url.Values
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		w.Write(openMeteoJSON)
+	}))
+	defer srv.Close()
+
+	p, err := NewProvider("openmeteo", ProviderConfig{BaseURL: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	o := p.(*OpenMeteo)
+	o.now = func() time.Time { return time.Unix(1713200400, 0) }
+
+	res, err := p.Forecast(context.Background(), 52.52, 13.42, WithUnits(UnitsSI))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if query.Get("latitude") != "52.52" || query.Get("timeformat") != "unixtime" || query.Get("wind_speed_unit") != "ms" {
+		t.Errorf("unexpected query %v", query)
+	}
+
+	if !strings.Contains(query.Get("hourly"), "precipitation_probability") || !strings.Contains(query.Get("daily"), "sunrise") {
+		t.Errorf("expected hourly and daily variables to be requested got %v", query)
+	}
+
+	if res.Timezone != "Europe/Berlin" || res.Offset != 2 {
+		t.Errorf("expected the Berlin zone got %s %v", res.Timezone, res.Offset)
+	}
+
+	if res.Flags.Units != UnitsSI || len(res.Flags.Sources) != 1 || res.Flags.Sources[0] != "open-meteo" {
+		t.Errorf("unexpected flags %+v", res.Flags)
+	}
+
+	c := res.Currently
+	if c.Icon != IconRain || c.Summary != "Light Rain" || c.PrecipType != PrecipRain {
+		t.Errorf("unexpected current conditions %s %q %s", c.Icon, c.Summary, c.PrecipType)
+	}
+
+	if *c.Temperature != 14.2 || !approx(*c.Humidity, 0.71) || !approx(*c.CloudCover, 1) || !approx(*c.Visibility, 24.14) {
+		t.Errorf("unexpected current values %v %v %v %v", *c.Temperature, *c.Humidity, *c.CloudCover, *c.Visibility)
+	}
+
+	// 0.3mm in a 15 minute interval
+	if !approx(*c.PrecipIntensity, 1.2) {
+		t.Errorf("expected current precipitation as an hourly rate got %v", *c.PrecipIntensity)
+	}
+
+	if _, offset := c.Time.Time().Zone(); offset != 2*3600 {
+		t.Errorf("expected the time in the Berlin zone got %v", c.Time)
+	}
+
+	// the hour before now is trimmed
+	if len(res.Hourly.Data) != 2 || !res.Hourly.Data[0].Time.Equal(c.Time) {
+		t.Fatalf("expected hourly data from the current hour got %d points", len(res.Hourly.Data))
+	}
+
+	h := res.Hourly.Data[1]
+	if h.Icon != IconThunderstorm || h.Visibility != nil || !approx(*h.PrecipProbability, 0.9) {
+		t.Errorf("unexpected hourly point %s %v %v", h.Icon, h.Visibility, *h.PrecipProbability)
+	}
+
+	if res.Hourly.Icon != IconThunderstorm || res.Hourly.Summary != "Thunderstorms" {
+		t.Errorf("expected the hourly summary from the worst hour got %s %q", res.Hourly.Icon, res.Hourly.Summary)
+	}
+
+	d := res.Daily.Data[0]
+	if d.Icon != IconRain || *d.TemperatureHigh != 15.1 || *d.TemperatureLow != 7.4 || !approx(*d.PrecipIntensity, 0.3) {
+		t.Errorf("unexpected daily values %s %v %v %v", d.Icon, *d.TemperatureHigh, *d.TemperatureLow, *d.PrecipIntensity)
+	}
+
+	if d.SunriseTime.Time().Unix() != 1713153873 || d.PrecipAccumulation != nil {
+		t.Errorf("unexpected daily sunrise %v or accumulation %v", d.SunriseTime, d.PrecipAccumulation)
+	}
+
+	res, err = p.Forecast(context.Background(), 52.52, 13.42, Exclude(BlockHourly, BlockDaily))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if query.Get("hourly") != "" || query.Get("daily") != "" {
+		t.Errorf("expected excluded blocks to be left out of the query got %v", query)
+	}
+
+	if res.Flags.Units != UnitsUS || !approx(*res.Currently.Temperature, 57.56) {
+		t.Errorf("expected us units got %s %v", res.Flags.Units, *res.Currently.Temperature)
+	}
+}
+
+func TestOpenMeteoTimeMachine(t *testing.T) {
+	var paths []string
+	var query url.Values
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		query = r.URL.Query()
+		w.Write(openMeteoJSON)
+	}))
+	defer srv.Close()
+
+	at := time.Unix(1713201000, 0).UTC()
+
+	o := NewOpenMeteo()
+	o.BaseURL = srv.URL + "/v1/forecast"
+	o.ArchiveURL = srv.URL + "/v1/archive"
+	o.now = func() time.Time { return at.Add(time.Hour) }
+
+	res, err := o.TimeMachine(context.Background(), 52.52, 13.42, at, WithUnits(UnitsSI))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if paths[0] != "/v1/forecast" || query.Get("start_date") != "2024-04-14" || query.Get("end_date") != "2024-04-16" {
+		t.Errorf("unexpected request %s %v", paths[0], query)
+	}
+
+	if !res.Currently.Time.Equal(res.Hourly.Data[1].Time) || *res.Currently.Temperature != 14.2 {
+		t.Errorf("expected the hour containing the time as current got %v", res.Currently.Time)
+	}
+
+	if len(res.Hourly.Data) != 3 {
+		t.Errorf("expected the whole day got %d hours", len(res.Hourly.Data))
+	}
+
+	o.now = func() time.Time { return at.AddDate(1, 0, 0) }
+
+	old, err := o.TimeMachine(context.Background(), 52.52, 13.42, at, WithUnits(UnitsUS))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if paths[1] != "/v1/archive" || strings.Contains(query.Get("hourly"), "visibility") {
+		t.Errorf("expected old requests to use the archive got %s %v", paths[1], query)
+	}
+
+	// the current conditions share their values with the hour they come from
+	if !approx(*old.Currently.Temperature, 57.56) || !approx(*old.Hourly.Data[1].Temperature, 57.56) {
+		t.Errorf("expected 57.56F once converted got %v and %v", *old.Currently.Temperature, *old.Hourly.Data[1].Temperature)
+	}
+}
+
+func TestOpenMeteoProviderURLs(t *testing.T) {
+	p, err := NewProvider("openmeteo", ProviderConfig{BaseURL: "http://localhost:8080/v1/forecast"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if o := p.(*OpenMeteo); o.BaseURL != "http://localhost:8080/v1/forecast" || o.ArchiveURL != openMeteoArchiveURL {
+		t.Errorf("BaseURL should not move the archive got %s %s", o.BaseURL, o.ArchiveURL)
+	}
+
+	p, err = NewProvider("openmeteo", ProviderConfig{Options: map[string]string{"archive_url": "http://localhost:8080/v1/archive"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if o := p.(*OpenMeteo); o.BaseURL != openMeteoBaseURL || o.ArchiveURL != "http://localhost:8080/v1/archive" {
+		t.Errorf("expected archive_url to set the archive got %s %s", o.BaseURL, o.ArchiveURL)
+	}
+}
+
+func TestOpenMeteoTimeMachineLocalDay(t *testing.T) {
+	// 22:00 UTC on the 15th is already midnight on the 16th in Berlin
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"latitude":52.52,"longitude":13.42,"utc_offset_seconds":7200,"timezone":"Europe/Berlin",` +
+			`"hourly":{"time":[1713214800,1713218400,1713222000],"temperature_2m":[11.0,10.5,10.1],"weather_code":[3,3,3]},` +
+			`"daily":{"time":[1713132000,1713218400],"weather_code":[63,3],"temperature_2m_max":[15.1,17.3]}}`))
+	}))
+	defer srv.Close()
+
+	at := time.Date(2024, time.April, 15, 22, 30, 0, 0, time.UTC)
+
+	o := NewOpenMeteo()
+	o.BaseURL = srv.URL
+	o.now = func() time.Time { return at }
+
+	res, err := o.TimeMachine(context.Background(), 52.52, 13.42, at, WithUnits(UnitsSI))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(res.Daily.Data) != 1 || *res.Daily.Data[0].TemperatureHigh != 17.3 {
+		t.Errorf("expected the 16th as the day got %v", res.Daily.Data)
+	}
+
+	if len(res.Hourly.Data) != 2 || !res.Hourly.Data[0].Time.Equal(UnixTime(time.Unix(1713218400, 0))) {
+		t.Errorf("expected the hours of the 16th got %v", res.Hourly.Data)
+	}
+
+	if !res.Currently.Time.Equal(res.Hourly.Data[0].Time) {
+		t.Errorf("expected the hour containing the time as current got %v", res.Currently.Time)
+	}
+}
+
+func TestOpenMeteoError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":true,"reason":"Latitude must be in range of -90 to 90°. Given: 91.0."}`))
+	}))
+	defer srv.Close()
+
+	o := NewOpenMeteo()
+	o.BaseURL = srv.URL
+
+	_, err := o.Forecast(context.Background(), 91, 13.42)
+	if !errors.Is(err, ErrBadRequest) || !strings.Contains(err.Error(), "Latitude must be in range") {
+		t.Errorf("expected a bad request with the reason got %v", err)
+	}
+}
+
+func TestWMOIcon(t *testing.T) {
+	tests := []struct {
+		code int
+		day  bool
+		icon Icon
+	}{
+		{0, true, IconClearDay},
+		{1, false, IconClearNight},
+		{2, false, IconPartlyCloudyNight},
+		{3, true, IconCloudy},
+		{48, true, IconFog},
+		{55, true, IconRain},
+		{66, true, IconSleet},
+		{75, true, IconSnow},
+		{86, true, IconSnow},
+		{96, false, IconThunderstorm},
+		{42, true, ""},
+	}
+
+	for _, test := range tests {
+		if icon := wmoIcon(test.code, test.day); icon != test.icon {
+			t.Errorf("code %d: expected %s got %s", test.code, test.icon, icon)
+		}
+	}
+}
@@ -0,0 +1,470 @@
+package darksky
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	openWeatherMapBaseURL = "https://api.openweathermap.org/data/3.0/onecall"
+)
+
+/*
+OpenWeatherMap is a Provider backed by the OpenWeatherMap One Call API.
+Whichever unit system the API answers in is converted into the units
+requested.
+*/
+type OpenWeatherMap struct {
+	HTTPConfig
+	BaseURL string
+	Key     string
+	// Units is the unit system asked of the API: "standard", "metric" or
+	// "imperial". When empty it is imperial for UnitsUS and metric otherwise.
+	Units string
+}
+
+/*
+NewOpenWeatherMap constructs an OpenWeatherMap provider using API key key
+*/
+func NewOpenWeatherMap(key string) *OpenWeatherMap {
+	return &OpenWeatherMap{
+		HTTPConfig: HTTPConfig{Timeout: defaultTimeout},
+		BaseURL:    openWeatherMapBaseURL,
+		Key:        key,
+	}
+}
+
+type owmResponse struct {
+	Lat            float32    `json:"lat"`
+	Lon            float32    `json:"lon"`
+	Timezone       string     `json:"timezone"`
+	TimezoneOffset int        `json:"timezone_offset"`
+	Current        *owmPoint  `json:"current"`
+	Minutely       []owmPoint `json:"minutely"`
+	Hourly         []owmPoint `json:"hourly"`
+	Daily          []owmDay   `json:"daily"`
+	Alerts         []owmAlert `json:"alerts"`
+	// Data holds the points of a time machine response
+	Data []owmPoint `json:"data"`
+}
+
+type owmWeather struct {
+	ID          int    `json:"id"`
+	Description string `json:"description"`
+	Icon        string `json:"icon"`
+}
+
+/*
+owmPoint is a current, minutely or hourly entry
+*/
+type owmPoint struct {
+	Dt            int64        `json:"dt"`
+	Sunrise       *float64     `json:"sunrise"`
+	Sunset        *float64     `json:"sunset"`
+	Temp          *float64     `json:"temp"`
+	FeelsLike     *float64     `json:"feels_like"`
+	Pressure      *float64     `json:"pressure"`
+	Humidity      *float64     `json:"humidity"`
+	DewPoint      *float64     `json:"dew_point"`
+	UVI           *float64     `json:"uvi"`
+	Clouds        *float64     `json:"clouds"`
+	Visibility    *float64     `json:"visibility"`
+	WindSpeed     *float64     `json:"wind_speed"`
+	WindGust      *float64     `json:"wind_gust"`
+	WindDeg       *float64     `json:"wind_deg"`
+	Pop           *float64     `json:"pop"`
+	Precipitation *float64     `json:"precipitation"`
+	Rain          owmHour      `json:"rain"`
+	Snow          owmHour      `json:"snow"`
+	Weather       []owmWeather `json:"weather"`
+}
+
+type owmHour struct {
+	OneHour *float64 `json:"1h"`
+}
+
+type owmDay struct {
+	Dt        int64    `json:"dt"`
+	Sunrise   *float64 `json:"sunrise"`
+	Sunset    *float64 `json:"sunset"`
+	MoonPhase *float64 `json:"moon_phase"`
+	Summary   string   `json:"summary"`
+	Temp      struct {
+		Min *float64 `json:"min"`
+		Max *float64 `json:"max"`
+	} `json:"temp"`
+	FeelsLike struct {
+		Day   *float64 `json:"day"`
+		Night *float64 `json:"night"`
+	} `json:"feels_like"`
+	Pressure  *float64     `json:"pressure"`
+	Humidity  *float64     `json:"humidity"`
+	DewPoint  *float64     `json:"dew_point"`
+	WindSpeed *float64     `json:"wind_speed"`
+	WindGust  *float64     `json:"wind_gust"`
+	WindDeg   *float64     `json:"wind_deg"`
+	Clouds    *float64     `json:"clouds"`
+	Pop       *float64     `json:"pop"`
+	Rain      *float64     `json:"rain"`
+	Snow      *float64     `json:"snow"`
+	UVI       *float64     `json:"uvi"`
+	Weather   []owmWeather `json:"weather"`
+}
+
+type owmAlert struct {
+	SenderName  string `json:"sender_name"`
+	Event       string `json:"event"`
+	Start       int64  `json:"start"`
+	End         int64  `json:"end"`
+	Description string `json:"description"`
+}
+
+/*
+owmUnits converts values in one of the OpenWeatherMap unit systems into SI
+*/
+type owmUnits string
+
+const (
+	owmStandard owmUnits = "standard"
+	owmMetric   owmUnits = "metric"
+	owmImperial owmUnits = "imperial"
+)
+
+func (u owmUnits) temperature(v *float64) *float32 {
+	if v == nil {
+		return nil
+	}
+
+	switch u {
+	case owmStandard:
+		return Float32(float32(*v - 273.15))
+	case owmImperial:
+		return Float32(float32((*v - 32) * 5 / 9))
+	}
+	return Float32(float32(*v))
+}
+
+func (u owmUnits) speed(v *float64) *float32 {
+	if v == nil {
+		return nil
+	}
+
+	if u == owmImperial {
+		return Float32(float32(*v * 0.44704))
+	}
+	return Float32(float32(*v))
+}
+
+/*
+Forecast implements Provider
+*/
+func (o *OpenWeatherMap) Forecast(ctx context.Context, lat, long float32, opts ...RequestOption) (Response, error) {
+	r := NewRequest(opts...)
+	if err := r.Validate(); err != nil {
+		return Response{}, err
+	}
+
+	q, units := o.query(lat, long, r)
+
+	var exclude []string
+	for _, b := range r.Exclude {
+		switch b {
+		case BlockCurrently:
+			exclude = append(exclude, "current")
+		case BlockMinutely, BlockHourly, BlockDaily, BlockAlerts:
+			exclude = append(exclude, string(b))
+		}
+	}
+	if len(exclude) > 0 {
+		q.Set("exclude", strings.Join(exclude, ","))
+	}
+
+	return o.get(ctx, o.BaseURL, q, r, units)
+}
+
+/*
+TimeMachine implements Provider, returning the conditions at t as Currently
+*/
+func (o *OpenWeatherMap) TimeMachine(ctx context.Context, lat, long float32, t time.Time, opts ...RequestOption) (Response, error) {
+	r := NewRequest(opts...)
+	if err := r.Validate(); err != nil {
+		return Response{}, err
+	}
+
+	q, units := o.query(lat, long, r)
+	q.Set("dt", strconv.FormatInt(t.Unix(), 10))
+
+	return o.get(ctx, o.BaseURL+"/timemachine", q, r, units)
+}
+
+func (o *OpenWeatherMap) query(lat, long float32, r Request) (url.Values, owmUnits) {
+	units := owmMetric
+	switch u := owmUnits(o.Units); {
+	case u == owmStandard || u == owmMetric || u == owmImperial:
+		units = u
+	case r.Units == UnitsUS:
+		units = owmImperial
+	}
+
+	q := url.Values{}
+	q.Set("lat", formatCoord(lat))
+	q.Set("lon", formatCoord(long))
+	q.Set("units", string(units))
+	q.Set("appid", o.Key)
+	if r.Lang != "" {
+		q.Set("lang", r.Lang)
+	}
+
+	return q, units
+}
+
+func (o *OpenWeatherMap) get(ctx context.Context, base string, q url.Values, r Request, units owmUnits) (Response, error) {
+	owm := owmResponse{}
+
+	if _, err := o.getJSON(ctx, base+"?"+q.Encode(), nil, o.Key, &owm); err != nil {
+		return Response{}, err
+	}
+
+	res := Response{
+		Latitude:  owm.Lat,
+		Longitude: owm.Lon,
+		Timezone:  owm.Timezone,
+		Offset:    float32(owm.TimezoneOffset) / 3600,
+		Flags: Flags{
+			Sources: []string{"openweathermap"},
+		},
+	}
+
+	if owm.Current != nil {
+		res.Currently = owmData(*owm.Current, units)
+	} else if len(owm.Data) > 0 {
+		res.Currently = owmData(owm.Data[0], units)
+	}
+
+	if len(owm.Minutely) > 0 {
+		res.Minutely = &DataSummary{Data: make([]Data, len(owm.Minutely))}
+		for i, m := range owm.Minutely {
+			res.Minutely.Data[i] = Data{
+				Time:            UnixTime(time.Unix(m.Dt, 0)),
+				PrecipIntensity: optional(m.Precipitation),
+			}
+		}
+	}
+
+	if len(owm.Hourly) > 0 {
+		res.Hourly = &DataSummary{Data: make([]Data, len(owm.Hourly))}
+		for i, h := range owm.Hourly {
+			res.Hourly.Data[i] = *owmData(h, units)
+		}
+	}
+
+	if len(owm.Daily) > 0 {
+		res.Daily = &DataSummary{Data: make([]Data, len(owm.Daily))}
+		for i, d := range owm.Daily {
+			res.Daily.Data[i] = owmDayData(d, units)
+		}
+	}
+
+	for _, a := range owm.Alerts {
+		alert := Alert{
+			Title:       a.Event,
+			Description: a.Description,
+			Time:        UnixTime(time.Unix(a.Start, 0)),
+			Severity:    eventSeverity(a.Event),
+		}
+		if alert.Severity == "" {
+			alert.Severity = SeverityAdvisory
+		}
+		if a.End != 0 {
+			expires := UnixTime(time.Unix(a.End, 0))
+			alert.Expires = &expires
+		}
+		res.Alerts = append(res.Alerts, alert)
+	}
+
+	if err := finishSI(&res, r); err != nil {
+		return Response{}, err
+	}
+
+	return res, nil
+}
+
+/*
+owmData converts a current or hourly entry into SI units
+*/
+func owmData(p owmPoint, units owmUnits) *Data {
+	d := &Data{
+		Time:                UnixTime(time.Unix(p.Dt, 0)),
+		SunriseTime:         unixTime(p.Sunrise),
+		SunsetTime:          unixTime(p.Sunset),
+		Temperature:         units.temperature(p.Temp),
+		ApparentTemperature: units.temperature(p.FeelsLike),
+		DewPoint:            units.temperature(p.DewPoint),
+		Pressure:            optional(p.Pressure),
+		Humidity:            percent(p.Humidity),
+		UVIndex:             optional(p.UVI),
+		CloudCover:          percent(p.Clouds),
+		Visibility:          optional(p.Visibility),
+		WindSpeed:           units.speed(p.WindSpeed),
+		WindGust:            units.speed(p.WindGust),
+		WindBearing:         optional(p.WindDeg),
+		PrecipProbability:   optional(p.Pop),
+	}
+
+	// visibility is always in meters
+	scale(d.Visibility, 0.001)
+
+	if p.Rain.OneHour != nil || p.Snow.OneHour != nil {
+		d.PrecipIntensity = Float32(float32(value(p.Rain.OneHour) + value(p.Snow.OneHour)))
+	}
+
+	d.Icon, d.Summary, d.PrecipType = owmWeatherOf(p.Weather)
+	switch {
+	case value(p.Snow.OneHour) > 0:
+		d.PrecipType = PrecipSnow
+	case value(p.Rain.OneHour) > 0 && d.PrecipType == "":
+		d.PrecipType = PrecipRain
+	}
+
+	return d
+}
+
+/*
+owmDayData converts a daily entry into SI units
+*/
+func owmDayData(p owmDay, units owmUnits) Data {
+	d := Data{
+		Time:                    UnixTime(time.Unix(p.Dt, 0)),
+		SunriseTime:             unixTime(p.Sunrise),
+		SunsetTime:              unixTime(p.Sunset),
+		MoonPhase:               optional(p.MoonPhase),
+		TemperatureHigh:         units.temperature(p.Temp.Max),
+		TemperatureMax:          units.temperature(p.Temp.Max),
+		TemperatureLow:          units.temperature(p.Temp.Min),
+		TemperatureMin:          units.temperature(p.Temp.Min),
+		ApparentTemperatureHigh: units.temperature(p.FeelsLike.Day),
+		ApparentTemperatureLow:  units.temperature(p.FeelsLike.Night),
+		DewPoint:                units.temperature(p.DewPoint),
+		Pressure:                optional(p.Pressure),
+		Humidity:                percent(p.Humidity),
+		UVIndex:                 optional(p.UVI),
+		CloudCover:              percent(p.Clouds),
+		WindSpeed:               units.speed(p.WindSpeed),
+		WindGust:                units.speed(p.WindGust),
+		WindBearing:             optional(p.WindDeg),
+		PrecipProbability:       optional(p.Pop),
+	}
+
+	if p.Rain != nil || p.Snow != nil {
+		d.PrecipIntensity = Float32(float32((value(p.Rain) + value(p.Snow)) / 24))
+	}
+
+	if value(p.Snow) > 0 {
+		// snow is given in mm, accumulation is in cm
+		d.PrecipAccumulation = Float32(float32(*p.Snow / 10))
+	}
+
+	d.Icon, d.Summary, d.PrecipType = owmWeatherOf(p.Weather)
+	if p.Summary != "" {
+		d.Summary = p.Summary
+	}
+
+	return d
+}
+
+func value(v *float64) float64 {
+	if v == nil {
+		return 0
+	}
+	return *v
+}
+
+/*
+owmWeatherOf maps the first condition of an entry onto an icon, a summary
+and the kind of precipitation
+*/
+func owmWeatherOf(weather []owmWeather) (Icon, string, PrecipType) {
+	if len(weather) == 0 {
+		return "", "", ""
+	}
+
+	w := weather[0]
+	icon := owmIcon(w.ID, !strings.HasSuffix(w.Icon, "n"))
+
+	summary := w.Description
+	if r, n := utf8.DecodeRuneInString(summary); r != utf8.RuneError {
+		summary = string(unicode.ToUpper(r)) + summary[n:]
+	}
+
+	var precip PrecipType
+	switch icon {
+	case IconRain, IconThunderstorm:
+		precip = PrecipRain
+	case IconSnow:
+		precip = PrecipSnow
+	case IconSleet:
+		precip = PrecipSleet
+	}
+
+	return icon, summary, precip
+}
+
+/*
+owmIcon maps an OpenWeatherMap condition ID onto a Darksky icon
+*/
+func owmIcon(id int, day bool) Icon {
+	switch {
+	case id >= 200 && id < 300:
+		return IconThunderstorm
+	case id == 511, id >= 611 && id <= 616:
+		return IconSleet
+	case id >= 300 && id < 600:
+		return IconRain
+	case id >= 600 && id < 700:
+		return IconSnow
+	case id == 771:
+		return IconWind
+	case id == 781:
+		return IconTornado
+	case id >= 700 && id < 800:
+		return IconFog
+	case id == 800:
+		if day {
+			return IconClearDay
+		}
+		return IconClearNight
+	case id == 801 || id == 802:
+		if day {
+			return IconPartlyCloudyDay
+		}
+		return IconPartlyCloudyNight
+	case id == 803 || id == 804:
+		return IconCloudy
+	}
+	return ""
+}
+
+func init() {
+	Register("openweathermap", func(cfg ProviderConfig) (Provider, error) {
+		if cfg.Key == "" {
+			return nil, errors.New("darksky: openweathermap provider needs a key")
+		}
+
+		o := NewOpenWeatherMap(cfg.Key)
+		o.Client = cfg.Client
+		o.UserAgent = cfg.UserAgent
+		o.Units = cfg.Options["units"]
+
+		if cfg.BaseURL != "" {
+			o.BaseURL = cfg.BaseURL
+		}
+
+		return o, nil
+	})
+}
@@ -0,0 +1,205 @@
+package darksky
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	oneCallJSON = []byte(`
+	{"lat":33.44,"lon":-94.04,"timezone":"America/Chicago","timezone_offset":-18000,
+	"current":{"dt":1684929490,"sunrise":1684926645,"sunset":1684977332,"temp":292.55,"feels_like":292.87,"pressure":1014,"humidity":89,"dew_point":290.69,"uvi":0.16,"clouds":53,"visibility":10000,"wind_speed":3.13,"wind_deg":93,"wind_gust":6.71,"rain":{"1h":0.52},"weather":[{"id":500,"main":"Rain","description":"light rain","icon":"10d"}]},
+	"minutely":[{"dt":1684929540,"precipitation":0},{"dt":1684929600,"precipitation":0.4}],
+	"hourly":[{"dt":1684926000,"temp":292.01,"feels_like":292.33,"pressure":1014,"humidity":91,"dew_point":290.51,"uvi":0,"clouds":54,"visibility":10000,"wind_speed":2.58,"wind_deg":86,"wind_gust":5.88,"weather":[{"id":803,"main":"Clouds","description":"broken clouds","icon":"04n"}],"pop":0.15},
+	{"dt":1684929600,"temp":292.55,"feels_like":292.87,"pressure":1014,"humidity":89,"dew_point":290.69,"uvi":0.16,"clouds":53,"visibility":10000,"wind_speed":3.13,"wind_deg":93,"wind_gust":6.71,"weather":[{"id":211,"main":"Thunderstorm","description":"thunderstorm","icon":"11d"}],"pop":0.8,"rain":{"1h":2.1}}],
+	"daily":[{"dt":1684951200,"sunrise":1684926645,"sunset":1684977332,"moonrise":1684941060,"moonset":1684905480,"moon_phase":0.16,"summary":"Expect a day of partly cloudy with rain","temp":{"day":299.03,"min":290.69,"max":300.35,"night":291.45,"eve":297.51,"morn":292.55},"feels_like":{"day":299.21,"night":291.37,"eve":297.86,"morn":292.87},"pressure":1016,"humidity":59,"dew_point":290.48,"wind_speed":3.98,"wind_deg":76,"wind_gust":8.92,"weather":[{"id":500,"main":"Rain","description":"light rain","icon":"10d"}],"clouds":92,"pop":0.47,"rain":0.15,"uvi":9.23}],
+	"alerts":[{"sender_name":"NWS Philadelphia - Mount Holly (New Jersey, Delaware, Southeastern Pennsylvania)","event":"Small Craft Advisory","start":1684952747,"end":1684988747,"description":"...SMALL CRAFT ADVISORY REMAINS IN EFFECT FROM 5 PM THIS AFTERNOON TO 3 AM EST FRIDAY...","tags":[]}]}
+	`)
+	oneCallTimeMachineJSON = []byte(`
+	{"lat":52.2297,"lon":21.0122,"timezone":"Europe/Warsaw","timezone_offset":3600,"data":[{"dt":1645888976,"sunrise":1645853361,"sunset":1645891727,"temp":39.2,"feels_like":33.1,"pressure":1029,"humidity":64,"dew_point":28.4,"uvi":0.06,"clouds":0,"visibility":10000,"wind_speed":8.05,"wind_deg":270,"weather":[{"id":800,"main":"Clear","description":"clear sky","icon":"01n"}]}]}
+	`)
+)
+
+func TestOpenWeatherMap(t *testing.T) {
+	var query url.Values
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		w.Write(oneCallJSON)
+	}))
+	defer srv.Close()
+
+	p, err := NewProvider("openweathermap", ProviderConfig{
+		Key:     "owm-key",
+		BaseURL: srv.URL,
+		Options: map[string]string{"units": "standard"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := p.Forecast(context.Background(), 33.44, -94.04, WithUnits(UnitsSI), WithLang("de"), Exclude(BlockMinutely, BlockCurrently))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if query.Get("appid") != "owm-key" || query.Get("units") != "standard" || query.Get("lang") != "de" || query.Get("exclude") != "minutely,current" {
+		t.Errorf("unexpected query %v", query)
+	}
+
+	if res.Timezone != "America/Chicago" || res.Offset != -5 || res.Flags.Units != UnitsSI || res.Flags.Sources[0] != "openweathermap" {
+		t.Errorf("unexpected zone %s %v or flags %+v", res.Timezone, res.Offset, res.Flags)
+	}
+
+	c := res.Currently
+	if !approx(*c.Temperature, 19.4) || !approx(*c.DewPoint, 17.54) || !approx(*c.Humidity, 0.89) || *c.Visibility != 10 {
+		t.Errorf("unexpected current values %v %v %v %v", *c.Temperature, *c.DewPoint, *c.Humidity, *c.Visibility)
+	}
+
+	if c.Icon != IconRain || c.Summary != "Light rain" || c.PrecipType != PrecipRain || *c.PrecipIntensity != 0.52 {
+		t.Errorf("unexpected current conditions %s %q %s %v", c.Icon, c.Summary, c.PrecipType, *c.PrecipIntensity)
+	}
+
+	if _, offset := c.Time.Time().Zone(); offset != -5*3600 {
+		t.Errorf("expected the time in the Chicago zone got %v", c.Time)
+	}
+
+	if len(res.Minutely.Data) != 2 || *res.Minutely.Data[1].PrecipIntensity != 0.4 {
+		t.Errorf("unexpected minutely data %+v", res.Minutely.Data)
+	}
+
+	if h := res.Hourly.Data[0]; h.Icon != IconCloudy || *h.PrecipProbability != 0.15 {
+		t.Errorf("unexpected first hour %s %v", h.Icon, *h.PrecipProbability)
+	}
+
+	if res.Hourly.Icon != IconThunderstorm {
+		t.Errorf("expected the hourly icon from the worst hour got %s", res.Hourly.Icon)
+	}
+
+	d := res.Daily.Data[0]
+	if !approx(*d.TemperatureHigh, 27.2) || !approx(*d.TemperatureLow, 17.54) || *d.MoonPhase != 0.16 || d.Summary != "Expect a day of partly cloudy with rain" {
+		t.Errorf("unexpected day %v %v %v %q", *d.TemperatureHigh, *d.TemperatureLow, *d.MoonPhase, d.Summary)
+	}
+
+	if len(res.Alerts) != 1 || res.Alerts[0].Severity != SeverityAdvisory || res.Alerts[0].Expires.Time().Unix() != 1684988747 {
+		t.Errorf("unexpected alerts %+v", res.Alerts)
+	}
+
+	if res, err = p.Forecast(context.Background(), 33.44, -94.04, WithUnits(UnitsUK2)); err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Flags.Units != UnitsUK2 || !approx(*res.Currently.Visibility, 6.2137) || !approx(*res.Currently.WindSpeed, 7.0016) {
+		t.Errorf("expected uk2 units got %s %v %v", res.Flags.Units, *res.Currently.Visibility, *res.Currently.WindSpeed)
+	}
+}
+
+func TestOpenWeatherMapTimeMachine(t *testing.T) {
+	var path string
+	var query url.Values
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, query = r.URL.Path, r.URL.Query()
+		w.Write(oneCallTimeMachineJSON)
+	}))
+	defer srv.Close()
+
+	o := NewOpenWeatherMap("owm-key")
+	o.BaseURL = srv.URL + "/data/3.0/onecall"
+
+	res, err := o.TimeMachine(context.Background(), 52.2297, 21.0122, time.Unix(1645888976, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if path != "/data/3.0/onecall/timemachine" || query.Get("dt") != "1645888976" || query.Get("units") != "imperial" {
+		t.Errorf("unexpected request %s %v", path, query)
+	}
+
+	c := res.Currently
+	if res.Flags.Units != UnitsUS || !approx(*c.Temperature, 39.2) || !approx(*c.WindSpeed, 8.05) || c.Icon != IconClearNight {
+		t.Errorf("unexpected conditions %s %v %v %s", res.Flags.Units, *c.Temperature, *c.WindSpeed, c.Icon)
+	}
+}
+
+func TestOpenWeatherMapLang(t *testing.T) {
+	var query url.Values
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		w.Write(bytes.Replace(oneCallTimeMachineJSON, []byte(`"clear sky"`), []byte(`"ясно"`), 1))
+	}))
+	defer srv.Close()
+
+	o := NewOpenWeatherMap("owm-key")
+	o.BaseURL = srv.URL
+
+	res, err := o.TimeMachine(context.Background(), 52.2297, 21.0122, time.Unix(1645888976, 0), WithLang("ru"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if query.Get("lang") != "ru" || res.Currently.Summary != "Ясно" {
+		t.Errorf("expected the Russian summary capitalized got %q %v", res.Currently.Summary, query)
+	}
+}
+
+func TestOpenWeatherMapUnauthorized(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"cod":401, "message": "Invalid API key. Please see https://openweathermap.org/faq#error401 for more info."}`))
+	}))
+	defer srv.Close()
+
+	o := NewOpenWeatherMap("owm-key")
+	o.BaseURL = srv.URL
+
+	_, err := o.Forecast(context.Background(), 33.44, -94.04)
+	if !errors.Is(err, ErrUnauthorized) || !strings.Contains(err.Error(), "Invalid API key") {
+		t.Errorf("expected unauthorized with the message got %v", err)
+	}
+
+	if strings.Contains(err.Error(), "owm-key") {
+		t.Errorf("expected the key to be redacted got %v", err)
+	}
+}
+
+func TestOpenWeatherMapNeedsKey(t *testing.T) {
+	if _, err := NewProvider("openweathermap", ProviderConfig{}); err == nil {
+		t.Errorf("expected an error without a key")
+	}
+}
+
+func TestOWMIcon(t *testing.T) {
+	tests := []struct {
+		id   int
+		day  bool
+		icon Icon
+	}{
+		{202, true, IconThunderstorm},
+		{310, true, IconRain},
+		{511, true, IconSleet},
+		{601, true, IconSnow},
+		{613, true, IconSleet},
+		{741, true, IconFog},
+		{771, true, IconWind},
+		{781, true, IconTornado},
+		{800, false, IconClearNight},
+		{802, true, IconPartlyCloudyDay},
+		{804, false, IconCloudy},
+		{900, true, ""},
+	}
+
+	for _, test := range tests {
+		if icon := owmIcon(test.id, test.day); icon != test.icon {
+			t.Errorf("id %d: expected %s got %s", test.id, test.icon, icon)
+		}
+	}
+}
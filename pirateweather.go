@@ -0,0 +1,63 @@
+package darksky
+
+import (
+	"errors"
+	"net/url"
+)
+
+const (
+	pirateWeatherBaseURL        = "https://api.pirateweather.net/forecast"
+	pirateWeatherTimeMachineURL = "https://timemachine.pirateweather.net/forecast"
+)
+
+/*
+NewPirateWeather constructs a Service for Pirate Weather, which serves the
+Darksky schema from its own endpoints. Its API keys go in the path just like
+Darksky's. Historical requests are sent to its separate Time Machine host.
+*/
+func NewPirateWeather(key string, opts ...Option) *Service {
+	s := NewService(key)
+	s.BaseURL = pirateWeatherBaseURL
+	s.TimeMachineURL = pirateWeatherTimeMachineURL
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+/*
+WithAPIVersion asks Pirate Weather for a schema version. Version 2 adds the
+Pirate Weather extensions to Data, such as Smoke and SnowAccumulation.
+*/
+func WithAPIVersion(version string) Option {
+	return func(s *Service) {
+		if s.Params == nil {
+			s.Params = url.Values{}
+		}
+		s.Params.Set("version", version)
+	}
+}
+
+func init() {
+	Register("pirateweather", func(cfg ProviderConfig) (Provider, error) {
+		if cfg.Key == "" {
+			return nil, errors.New("darksky: pirateweather provider needs a key")
+		}
+
+		opts := serviceOptions(cfg)
+
+		if u := cfg.Options["timemachine_url"]; u != "" {
+			opts = append(opts, func(s *Service) {
+				s.TimeMachineURL = u
+			})
+		}
+
+		if v := cfg.Options["version"]; v != "" {
+			opts = append(opts, WithAPIVersion(v))
+		}
+
+		return NewPirateWeather(cfg.Key, opts...), nil
+	})
+}
@@ -0,0 +1,115 @@
+package darksky
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var (
+	pirateWeatherJSON = []byte(`
+	{"latitude":45.42,"longitude":-75.69,"timezone":"America/Toronto","offset":-4.0,"elevation":69,"currently":{"time":1713200400,"summary":"Overcast","icon":"cloudy","nearestStormDistance":0,"nearestStormBearing":0,"precipIntensity":0.0,"precipProbability":0.0,"precipIntensityError":0.0,"precipType":"none","temperature":8.23,"apparentTemperature":5.67,"dewPoint":2.53,"humidity":0.67,"pressure":1016.83,"windSpeed":3.06,"windGust":6.92,"windBearing":180,"cloudCover":0.99,"uvIndex":1.58,"visibility":16.09,"ozone":377.51,"smoke":2.44,"fireIndex":5.12,"feelsLike":5.46,"currentDayIce":0.0,"currentDayLiquid":0.0,"currentDaySnow":0.0},"hourly":{"summary":"Overcast until evening.","icon":"cloudy","data":[{"time":1713200400,"summary":"Overcast","icon":"cloudy","precipIntensity":0.0,"precipProbability":0.0,"precipIntensityError":0.0,"precipAccumulation":0.0,"precipType":"none","temperature":8.23,"apparentTemperature":5.67,"dewPoint":2.53,"humidity":0.67,"pressure":1016.83,"windSpeed":3.06,"windGust":6.92,"windBearing":180,"cloudCover":0.99,"uvIndex":1.58,"visibility":16.09,"ozone":377.51,"smoke":2.44,"liquidAccumulation":0.0,"snowAccumulation":0.0,"iceAccumulation":0.0,"nearestStormDistance":0,"nearestStormBearing":0,"fireIndex":5.12,"feelsLike":5.46}]},"daily":{"summary":"Rain on Wednesday.","icon":"rain","data":[{"time":1713153600,"summary":"Mostly cloudy throughout the day.","icon":"partly-cloudy-day","sunriseTime":1713175662,"sunsetTime":1713224477,"moonPhase":0.24,"precipIntensity":0.0,"precipIntensityMax":0.0,"precipIntensityMaxTime":1713153600,"precipProbability":0.0,"precipAccumulation":0.0,"precipType":"none","temperatureHigh":12.48,"temperatureHighTime":1713211200,"temperatureLow":2.71,"temperatureLowTime":1713265200,"apparentTemperatureHigh":10.93,"apparentTemperatureHighTime":1713211200,"apparentTemperatureLow":0.33,"apparentTemperatureLowTime":1713265200,"dewPoint":1.27,"humidity":0.62,"pressure":1017.32,"windSpeed":2.67,"windGust":6.51,"windGustTime":1713207600,"windBearing":190,"cloudCover":0.73,"uvIndex":3.4,"uvIndexTime":1713200400,"visibility":16.09,"temperatureMin":2.71,"temperatureMinTime":1713265200,"temperatureMax":12.48,"temperatureMaxTime":1713211200,"apparentTemperatureMin":0.33,"apparentTemperatureMinTime":1713265200,"apparentTemperatureMax":10.93,"apparentTemperatureMaxTime":1713211200,"smokeMax":4.61,"smokeMaxTime":1713229200,"liquidAccumulation":0.0,"snowAccumulation":0.0,"iceAccumulation":0.0,"fireIndexMax":8.31,"fireIndexMaxTime":1713211200}]},"flags":{"sources":["ETOPO1","hrrrsubh","rtma_ru","hrrr_0-18","nbm","nbm_fire","hrrr_18-48","gfs","gefs"],"sourceTimes":{"hrrr_subh":"2024-04-15 16Z","hrrr_0-18":"2024-04-15 16Z","nbm":"2024-04-15 15Z","nbm_fire":"2024-04-15 12Z","hrrr_18-48":"2024-04-15 12Z","gfs":"2024-04-15 06Z","gefs":"2024-04-15 06Z"},"nearest-station":0,"units":"si","version":"V2.0.2","sourceIDX":{"hrrr":{"x":1564,"y":744},"nbm":{"x":1880,"y":1048},"gfs":{"x":1137,"y":542}},"processTime":11253}}
+	`)
+)
+
+func TestPirateWeather(t *testing.T) {
+	var paths, queries []string
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		queries = append(queries, r.URL.RawQuery)
+		w.Write(pirateWeatherJSON)
+	}))
+	defer srv.Close()
+
+	p, err := NewProvider("pirateweather", ProviderConfig{
+		Key:     "pw-key",
+		BaseURL: srv.URL,
+		Options: map[string]string{"version": "2", "timemachine_url": srv.URL},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := p.Forecast(context.Background(), 45.42, -75.69, WithUnits(UnitsSI))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if paths[0] != "/pw-key/45.42,-75.69" || queries[0] != "exclude=minutely&units=si&version=2" {
+		t.Errorf("unexpected request %s?%s", paths[0], queries[0])
+	}
+
+	if res.Flags.Version != "V2.0.2" || res.Flags.SourceTimes["hrrr_0-18"] != "2024-04-15 16Z" || res.Flags.Units != UnitsSI {
+		t.Errorf("flags not decoded: %+v", res.Flags)
+	}
+
+	c := res.Currently
+	if Float32Value(c.Smoke) != 2.44 || Float32Value(c.FireIndex) != 5.12 || Float32Value(c.FeelsLike) != 5.46 {
+		t.Errorf("extensions not decoded: %v %v %v", c.Smoke, c.FireIndex, c.FeelsLike)
+	}
+
+	if _, offset := c.Time.Time().Zone(); offset != -4*3600 {
+		t.Errorf("expected the time in the Toronto zone got %v", c.Time)
+	}
+
+	if err := res.ConvertTo(UnitsUS); err != nil {
+		t.Fatal(err)
+	}
+
+	if !approx(*c.FeelsLike, 41.828) {
+		t.Errorf("expected feelsLike to convert got %v", *c.FeelsLike)
+	}
+
+	if _, err := p.TimeMachine(context.Background(), 45.42, -75.69, time.Unix(1713200400, 0)); err != nil {
+		t.Fatal(err)
+	}
+
+	if paths[1] != "/pw-key/45.42,-75.69,1713200400" {
+		t.Errorf("unexpected time machine path %s", paths[1])
+	}
+
+	kept, err := NewPirateWeather("pw-key", WithBaseURL(srv.URL), WithExtraFields()).Forecast(context.Background(), 45.42, -75.69)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(kept.Flags.Extra["processTime"]) != "11253" {
+		t.Errorf("expected processTime to be kept in extra got %v", kept.Flags.Extra)
+	}
+
+	if string(kept.Daily.Data[0].Extra["smokeMaxTime"]) != "1713229200" {
+		t.Errorf("expected unknown daily fields in extra got %v", kept.Daily.Data[0].Extra)
+	}
+}
+
+func TestPirateWeatherEndpoints(t *testing.T) {
+	s := NewPirateWeather("pw-key")
+
+	if u := s.URL(45.42, -75.69, NewRequest()); u != "https://api.pirateweather.net/forecast/pw-key/45.42,-75.69?exclude=minutely&units=us" {
+		t.Errorf("unexpected forecast url %s", u)
+	}
+
+	if u := s.URL(45.42, -75.69, NewRequest(At(time.Unix(1713200400, 0)))); u != "https://timemachine.pirateweather.net/forecast/pw-key/45.42,-75.69,1713200400?exclude=minutely&units=us" {
+		t.Errorf("unexpected time machine url %s", u)
+	}
+}
+
+func TestPirateWeatherBaseURL(t *testing.T) {
+	p, err := NewProvider("pirateweather", ProviderConfig{Key: "pw-key", BaseURL: "http://localhost:8080/forecast"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := p.(*Service)
+
+	if u := s.URL(45.42, -75.69, NewRequest()); u != "http://localhost:8080/forecast/pw-key/45.42,-75.69?exclude=minutely&units=us" {
+		t.Errorf("unexpected forecast url %s", u)
+	}
+
+	if u := s.URL(45.42, -75.69, NewRequest(At(time.Unix(1713200400, 0)))); u != "https://timemachine.pirateweather.net/forecast/pw-key/45.42,-75.69,1713200400?exclude=minutely&units=us" {
+		t.Errorf("BaseURL should not move the time machine endpoint got %s", u)
+	}
+}
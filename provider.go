@@ -0,0 +1,283 @@
+package darksky
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+ErrUnsupported is returned by providers for requests their backend cannot
+answer, such as Time Machine requests to a forecast-only API
+*/
+var ErrUnsupported = errors.New("darksky: not supported by provider")
+
+/*
+Provider is a weather backend that answers in the Darksky Response model.
+Providers interpret RequestOptions as closely as their API allows; values they
+cannot honor, such as a language, are ignored.
+*/
+type Provider interface {
+	// Forecast gets the current conditions and forecast for a location
+	Forecast(ctx context.Context, lat, long float32, opts ...RequestOption) (Response, error)
+	// TimeMachine gets observed or forecast conditions at time t
+	TimeMachine(ctx context.Context, lat, long float32, t time.Time, opts ...RequestOption) (Response, error)
+}
+
+/*
+ProviderConfig is the configuration passed to a provider factory
+*/
+type ProviderConfig struct {
+	// Key is the API key, for providers that need one
+	Key string
+	// BaseURL overrides the provider's default endpoint
+	BaseURL string
+	// Client performs the requests. When nil DefaultClient is used.
+	Client *http.Client
+	// UserAgent identifies the application, for providers that require it
+	UserAgent string
+	// Options holds provider-specific settings
+	Options map[string]string
+}
+
+/*
+ProviderFactory creates a Provider from its configuration
+*/
+type ProviderFactory func(cfg ProviderConfig) (Provider, error)
+
+var (
+	providersMu sync.RWMutex
+	providers   = make(map[string]ProviderFactory)
+)
+
+/*
+Register makes a provider available by name to NewProvider. It panics if the
+name is registered twice or the factory is nil.
+*/
+func Register(name string, factory ProviderFactory) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+
+	if factory == nil {
+		panic("darksky: Register factory is nil")
+	}
+	if _, dup := providers[name]; dup {
+		panic("darksky: Register called twice for provider " + name)
+	}
+
+	providers[name] = factory
+}
+
+/*
+NewProvider creates the provider registered under name
+*/
+func NewProvider(name string, cfg ProviderConfig) (Provider, error) {
+	providersMu.RLock()
+	factory, ok := providers[name]
+	providersMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("darksky: unknown provider %q", name)
+	}
+
+	return factory(cfg)
+}
+
+/*
+Providers returns the sorted names of the registered providers
+*/
+func Providers() []string {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+var (
+	_ Provider = (*Service)(nil)
+	_ Provider = (*CachedService)(nil)
+)
+
+/*
+Forecast implements Provider
+*/
+func (s *Service) Forecast(ctx context.Context, lat, long float32, opts ...RequestOption) (Response, error) {
+	return s.GetContext(ctx, lat, long, opts...)
+}
+
+/*
+TimeMachine implements Provider
+*/
+func (s *Service) TimeMachine(ctx context.Context, lat, long float32, t time.Time, opts ...RequestOption) (Response, error) {
+	return s.GetAt(ctx, lat, long, t, opts...)
+}
+
+/*
+Forecast implements Provider
+*/
+func (c *CachedService) Forecast(ctx context.Context, lat, long float32, opts ...RequestOption) (Response, error) {
+	return c.GetContext(ctx, lat, long, opts...)
+}
+
+/*
+TimeMachine implements Provider
+*/
+func (c *CachedService) TimeMachine(ctx context.Context, lat, long float32, t time.Time, opts ...RequestOption) (Response, error) {
+	return c.GetAt(ctx, lat, long, t, opts...)
+}
+
+/*
+HTTPConfig holds the HTTP settings of the providers whose APIs are not
+Darksky-compatible
+*/
+type HTTPConfig struct {
+	// Timeout bounds each call. Zero disables it.
+	Timeout time.Duration
+	// Client performs the requests. When nil DefaultClient is used.
+	Client *http.Client
+	// UserAgent is sent with each request when set
+	UserAgent string
+}
+
+/*
+getJSON gets u with the given extra headers and decodes a 2xx body into v.
+The returned response's body is already closed; callers may inspect its
+status and headers, including a 304 that leaves v untouched. key is redacted
+from errors.
+*/
+func (h HTTPConfig) getJSON(ctx context.Context, u string, header http.Header, key string, v interface{}) (*http.Response, error) {
+	if h.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.Timeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, redactError(err, key)
+	}
+
+	for k, vs := range header {
+		req.Header[k] = vs
+	}
+	req.Header.Set("Accept-Encoding", "gzip")
+	if h.UserAgent != "" {
+		req.Header.Set("User-Agent", h.UserAgent)
+	}
+
+	client := h.Client
+	if client == nil {
+		client = DefaultClient
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, redactError(contextError(ctx, err), key)
+	}
+	defer closeBody(res.Body)
+
+	if res.StatusCode == http.StatusNotModified {
+		return res, nil
+	}
+
+	if res.StatusCode/100 != 2 {
+		return res, newAPIError(res, errorBody(res), key)
+	}
+
+	body, err := openBody(res)
+	if err != nil {
+		return res, err
+	}
+
+	if err := decodeBody(body, defaultMaxBodySize, v); err != nil {
+		return res, contextError(ctx, err)
+	}
+
+	return res, nil
+}
+
+/*
+fromSI finishes a response a provider built in SI units, converting it to the
+units the request asked for. Auto resolves to SI.
+*/
+func fromSI(res *Response, u Units) error {
+	res.Flags.Units = UnitsSI
+
+	if u == "" || u == UnitsAuto || u == UnitsSI {
+		return nil
+	}
+	return res.ConvertTo(u)
+}
+
+/*
+finishSI does what every provider does last to a response it built in SI
+units: it summarizes the data blocks, localizes the times, converts to the
+units r asks for and leaves out the flags if r excludes them
+*/
+func finishSI(res *Response, r Request) error {
+	for _, block := range []*DataSummary{res.Minutely, res.Hourly, res.Daily} {
+		if block != nil {
+			block.Icon, block.Summary = summarize(block.Data)
+		}
+	}
+
+	res.Localize()
+
+	if err := fromSI(res, r.Units); err != nil {
+		return err
+	}
+
+	if r.Excludes(BlockFlags) {
+		res.Flags = Flags{}
+	}
+
+	return nil
+}
+
+/*
+roundCoord formats a coordinate to at most four decimal places, the most some
+APIs accept
+*/
+func roundCoord(v float32) string {
+	s := strconv.FormatFloat(float64(v), 'f', 4, 32)
+	s = strings.TrimRight(s, "0")
+	return strings.TrimSuffix(s, ".")
+}
+
+/*
+serviceOptions turns the generic parts of a ProviderConfig into Options
+*/
+func serviceOptions(cfg ProviderConfig) []Option {
+	var opts []Option
+
+	if cfg.Client != nil {
+		opts = append(opts, WithHTTPClient(cfg.Client))
+	}
+
+	if cfg.BaseURL != "" {
+		opts = append(opts, WithBaseURL(cfg.BaseURL))
+	}
+
+	return opts
+}
+
+func init() {
+	Register("darksky", func(cfg ProviderConfig) (Provider, error) {
+		if cfg.Key == "" {
+			return nil, errors.New("darksky: darksky provider needs a key")
+		}
+		return NewService(cfg.Key, serviceOptions(cfg)...), nil
+	})
+}
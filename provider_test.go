@@ -0,0 +1,59 @@
+package darksky
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestProviderRegistry(t *testing.T) {
+	var path string
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		w.Write(timeMachineJSON)
+	}))
+	defer srv.Close()
+
+	p, err := NewProvider("darksky", ProviderConfig{Key: "key", BaseURL: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := p.TimeMachine(context.Background(), 37.8267, -122.4233, time.Unix(1551600000, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if path != "/key/37.8267,-122.4233,1551600000" || res.Latitude != 37.8267 {
+		t.Errorf("unexpected request %s", path)
+	}
+
+	if _, err := NewProvider("darksky", ProviderConfig{}); err == nil {
+		t.Errorf("expected an error without a key")
+	}
+
+	if _, err := NewProvider("nope", ProviderConfig{}); err == nil {
+		t.Errorf("expected an error for an unknown provider")
+	}
+
+	found := false
+	for _, name := range Providers() {
+		found = found || name == "darksky"
+	}
+	if !found {
+		t.Errorf("expected darksky in %v", Providers())
+	}
+}
+
+func TestProviderRegisterTwice(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected registering darksky twice to panic")
+		}
+	}()
+
+	Register("darksky", func(cfg ProviderConfig) (Provider, error) { return nil, nil })
+}
@@ -0,0 +1,240 @@
+package darksky
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+Block names one of the data blocks of a forecast that can be excluded from a
+request
+*/
+type Block string
+
+const (
+	BlockCurrently Block = "currently"
+	BlockMinutely  Block = "minutely"
+	BlockHourly    Block = "hourly"
+	BlockDaily     Block = "daily"
+	BlockAlerts    Block = "alerts"
+	BlockFlags     Block = "flags"
+)
+
+func (b Block) valid() bool {
+	switch b {
+	case BlockCurrently, BlockMinutely, BlockHourly, BlockDaily, BlockAlerts, BlockFlags:
+		return true
+	}
+	return false
+}
+
+/*
+Units is the unit system Darksky reports values in
+*/
+type Units string
+
+const (
+	UnitsUS   Units = "us"
+	UnitsSI   Units = "si"
+	UnitsCA   Units = "ca"
+	UnitsUK2  Units = "uk2"
+	UnitsAuto Units = "auto"
+)
+
+func (u Units) valid() bool {
+	switch u {
+	case UnitsUS, UnitsSI, UnitsCA, UnitsUK2, UnitsAuto:
+		return true
+	}
+	return false
+}
+
+/*
+TimeFormat selects how the time of a Time Machine request is written in the URL
+*/
+type TimeFormat int
+
+const (
+	// TimeUnix writes the time as UNIX seconds
+	TimeUnix TimeFormat = iota
+	// TimeISO8601 writes the time as [YYYY]-[MM]-[DD]T[HH]:[MM]:[SS] followed
+	// by Z or the time's own offset from GMT
+	TimeISO8601
+)
+
+const iso8601Format = "2006-01-02T15:04:05Z0700"
+
+/*
+Request holds the optional parameters of a forecast request. The zero value
+asks Darksky for its own defaults; NewRequest starts from the package defaults
+of excluding the minutely block and using US units.
+*/
+type Request struct {
+	Exclude      []Block
+	Units        Units
+	Lang         string
+	ExtendHourly bool
+	// Time makes the request a Time Machine request for the given moment. The
+	// zero value requests the current forecast.
+	Time       time.Time
+	TimeFormat TimeFormat
+}
+
+/*
+RequestOption adjusts a Request
+*/
+type RequestOption func(*Request)
+
+/*
+Exclude replaces the set of blocks left out of the response
+*/
+func Exclude(blocks ...Block) RequestOption {
+	return func(r *Request) {
+		r.Exclude = append([]Block(nil), blocks...)
+	}
+}
+
+/*
+WithUnits sets the unit system of the response
+*/
+func WithUnits(u Units) RequestOption {
+	return func(r *Request) {
+		r.Units = u
+	}
+}
+
+/*
+WithLang sets the language of the textual summaries
+*/
+func WithLang(lang string) RequestOption {
+	return func(r *Request) {
+		r.Lang = lang
+	}
+}
+
+/*
+ExtendHourly asks for 168 hours of hourly data instead of 48
+*/
+func ExtendHourly() RequestOption {
+	return func(r *Request) {
+		r.ExtendHourly = true
+	}
+}
+
+/*
+At turns the request into a Time Machine request for t, which may be in the
+past or the future
+*/
+func At(t time.Time) RequestOption {
+	return func(r *Request) {
+		r.Time = t
+	}
+}
+
+/*
+WithTimeFormat sets how a Time Machine request's time is formatted
+*/
+func WithTimeFormat(f TimeFormat) RequestOption {
+	return func(r *Request) {
+		r.TimeFormat = f
+	}
+}
+
+/*
+NewRequest builds a Request from the package defaults and opts
+*/
+func NewRequest(opts ...RequestOption) Request {
+	r := Request{
+		Exclude: []Block{BlockMinutely},
+		Units:   UnitsUS,
+	}
+
+	for _, opt := range opts {
+		opt(&r)
+	}
+
+	return r
+}
+
+/*
+Validate reports unknown blocks or units
+*/
+func (r Request) Validate() error {
+	for _, b := range r.Exclude {
+		if !b.valid() {
+			return fmt.Errorf("darksky: unknown block %q", b)
+		}
+	}
+
+	if r.Units != "" && !r.Units.valid() {
+		return fmt.Errorf("darksky: unknown units %q", r.Units)
+	}
+
+	if r.TimeFormat != TimeUnix && r.TimeFormat != TimeISO8601 {
+		return fmt.Errorf("darksky: unknown time format %d", r.TimeFormat)
+	}
+
+	return nil
+}
+
+/*
+Excludes reports whether block b is left out of the response
+*/
+func (r Request) Excludes(b Block) bool {
+	for _, e := range r.Exclude {
+		if e == b {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+Query encodes the request as URL query parameters
+*/
+func (r Request) Query() url.Values {
+	q := url.Values{}
+
+	if len(r.Exclude) > 0 {
+		blocks := make([]string, 0, len(r.Exclude))
+		for _, b := range r.Exclude {
+			blocks = append(blocks, string(b))
+		}
+		sort.Strings(blocks)
+		q.Set("exclude", strings.Join(blocks, ","))
+	}
+
+	if r.ExtendHourly {
+		q.Set("extend", string(BlockHourly))
+	}
+
+	if r.Lang != "" {
+		q.Set("lang", r.Lang)
+	}
+
+	if r.Units != "" {
+		q.Set("units", string(r.Units))
+	}
+
+	return q
+}
+
+/*
+formatTime returns the time segment of a Time Machine URL, or the empty string
+for a current forecast
+*/
+func (r Request) formatTime() string {
+	if r.Time.IsZero() {
+		return ""
+	}
+
+	if r.TimeFormat == TimeISO8601 {
+		return r.Time.Format(iso8601Format)
+	}
+
+	return strconv.FormatInt(r.Time.Unix(), 10)
+}
@@ -0,0 +1,49 @@
+package darksky
+
+import (
+	"testing"
+)
+
+func TestRequestURL(t *testing.T) {
+	s := NewService("a/key")
+
+	tests := []struct {
+		opts []RequestOption
+		want string
+	}{
+		{nil, "https://api.darksky.net/forecast/a%2Fkey/37.8267,-122.4233?exclude=minutely&units=us"},
+		{[]RequestOption{Exclude()}, "https://api.darksky.net/forecast/a%2Fkey/37.8267,-122.4233?units=us"},
+		{[]RequestOption{Exclude(BlockHourly, BlockAlerts), WithUnits(UnitsSI)}, "https://api.darksky.net/forecast/a%2Fkey/37.8267,-122.4233?exclude=alerts%2Chourly&units=si"},
+		{[]RequestOption{WithLang("zh-tw"), ExtendHourly()}, "https://api.darksky.net/forecast/a%2Fkey/37.8267,-122.4233?exclude=minutely&extend=hourly&lang=zh-tw&units=us"},
+	}
+
+	for _, test := range tests {
+		if got := s.URL(37.8267, -122.4233, NewRequest(test.opts...)); got != test.want {
+			t.Errorf("expected %s got %s", test.want, got)
+		}
+	}
+}
+
+func TestRequestURLFormat(t *testing.T) {
+	s := NewService("key")
+	s.URLFormat = "http://localhost:8080/forecast/%s/%f,%f?exclude=minutely&units=us"
+
+	want := "http://localhost:8080/forecast/key/37.8267,-122.4233?exclude=minutely&units=si"
+	if got := s.URL(37.8267, -122.4233, NewRequest(WithUnits(UnitsSI))); got != want {
+		t.Errorf("expected %s got %s", want, got)
+	}
+}
+
+func TestRequestValidate(t *testing.T) {
+	if err := NewRequest(WithUnits("kelvin")).Validate(); err == nil {
+		t.Errorf("expected an error for unknown units")
+	}
+
+	if err := NewRequest(Exclude("weekly")).Validate(); err == nil {
+		t.Errorf("expected an error for unknown block")
+	}
+
+	if err := NewRequest(WithUnits(UnitsUK2), Exclude(BlockFlags)).Validate(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,183 @@
+package darksky
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+/*
+DefaultRetryPolicy makes up to three attempts, backing off from 250ms
+*/
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   250 * time.Millisecond,
+	MaxDelay:    10 * time.Second,
+	Jitter:      0.5,
+}
+
+var errDeadlineTooSoon = errors.New("darksky: deadline before next attempt")
+
+/*
+RetryPolicy describes how a Service retries transient failures: connection
+errors, timed out attempts and the status codes accepted by RetryStatus.
+Client errors such as an invalid key or location are never retried.
+*/
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first
+	MaxAttempts int
+	// BaseDelay is the wait before the second attempt; each later wait
+	// doubles it, up to MaxDelay
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// Jitter is the fraction, between 0 and 1, of each wait that is
+	// randomized to keep clients from retrying in lockstep
+	Jitter float64
+	// RetryStatus reports whether a status code is worth retrying. When nil
+	// RetryableStatus is used.
+	RetryStatus func(status int) bool
+}
+
+/*
+WithRetry makes the service retry transient failures according to p
+*/
+func WithRetry(p RetryPolicy) Option {
+	return func(s *Service) {
+		s.Retry = &p
+	}
+}
+
+/*
+RetryableStatus accepts 429 and the 5xx codes that signal a temporary outage
+*/
+func RetryableStatus(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+/*
+retryable reports whether err from an attempt made under ctx is worth another
+attempt
+*/
+func (p *RetryPolicy) retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		if p.RetryStatus != nil {
+			return p.RetryStatus(apiErr.StatusCode)
+		}
+		return RetryableStatus(apiErr.StatusCode)
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		// the attempt's own timeout fired, not the caller's
+		return true
+	}
+
+	// only failures of the connection itself are transient; certificate,
+	// scheme and redirect errors also arrive as *url.Error but would fail
+	// the same way again
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	var temp interface{ Temporary() bool }
+	if errors.As(err, &temp) && temp.Temporary() {
+		return true
+	}
+
+	return errors.Is(err, syscall.ECONNRESET) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+/*
+delay returns how long to wait after the given failed attempt. A Retry-After
+from the server takes precedence over the computed backoff, but is still held
+to MaxDelay.
+*/
+func (p *RetryPolicy) delay(attempt int, err error) time.Duration {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+		if p.MaxDelay > 0 && apiErr.RetryAfter > p.MaxDelay {
+			return p.MaxDelay
+		}
+		return apiErr.RetryAfter
+	}
+
+	d := p.BaseDelay
+	for i := 1; i < attempt && (p.MaxDelay <= 0 || d < p.MaxDelay); i++ {
+		d *= 2
+	}
+
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+
+	if p.Jitter > 0 {
+		j := p.Jitter
+		if j > 1 {
+			j = 1
+		}
+		d -= time.Duration(j * rand.Float64() * float64(d))
+	}
+
+	return d
+}
+
+/*
+wait sleeps for d unless ctx ends first. If ctx's deadline is sooner than d it
+returns errDeadlineTooSoon immediately rather than sleeping in vain.
+*/
+func wait(ctx context.Context, d time.Duration) error {
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < d {
+		return errDeadlineTooSoon
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+/*
+parseRetryAfter reads a Retry-After header given either in seconds or as an
+HTTP date
+*/
+func parseRetryAfter(h string) time.Duration {
+	if h == "" {
+		return 0
+	}
+
+	if secs, err := strconv.Atoi(h); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+
+	if t, err := http.ParseTime(h); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,182 @@
+package darksky
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRetryTransient(t *testing.T) {
+	calls := 0
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write(exampleJSON)
+	}))
+	defer srv.Close()
+
+	s := NewService("key", WithRetry(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}))
+	s.BaseURL = srv.URL
+
+	if _, err := s.GetContext(context.Background(), 37.8267, -122.4233); err != nil {
+		t.Fatal(err)
+	}
+
+	if calls != 3 {
+		t.Errorf("expected 3 attempts got %d", calls)
+	}
+}
+
+func TestRetryConnectionReset(t *testing.T) {
+	calls := 0
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.(*net.TCPConn).SetLinger(0)
+			conn.Close()
+			return
+		}
+		w.Write(exampleJSON)
+	}))
+	defer srv.Close()
+
+	s := NewService("key", WithRetry(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}))
+	s.BaseURL = srv.URL
+
+	if _, err := s.GetContext(context.Background(), 37.8267, -122.4233); err != nil {
+		t.Fatal(err)
+	}
+
+	if calls != 2 {
+		t.Errorf("expected a retry after the reset got %d attempts", calls)
+	}
+}
+
+func TestRetryNotOnClientErrors(t *testing.T) {
+	calls := 0
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer srv.Close()
+
+	s := NewService("key", WithRetry(RetryPolicy{MaxAttempts: 5, BaseDelay: time.Millisecond}))
+	s.BaseURL = srv.URL
+
+	if _, err := s.GetContext(context.Background(), 37.8267, -122.4233); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("expected %v got %v", ErrUnauthorized, err)
+	}
+
+	if calls != 1 {
+		t.Errorf("expected a single attempt got %d", calls)
+	}
+}
+
+func TestRetryNotOnPermanentErrors(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(exampleJSON)
+	}))
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+	srv.StartTLS()
+	defer srv.Close()
+
+	tests := []struct {
+		name string
+		url  string
+	}{
+		{"untrusted certificate", srv.URL},
+		{"unsupported scheme", "ftp://api.darksky.net/forecast"},
+	}
+
+	for _, test := range tests {
+		rt := &countingTransport{rt: http.DefaultTransport}
+
+		s := NewService("key", WithTransport(rt), WithRetry(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}))
+		s.BaseURL = test.url
+
+		if _, err := s.GetContext(context.Background(), 37.8267, -122.4233); err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+
+		if rt.calls != 1 {
+			t.Errorf("%s: expected a single attempt got %d", test.name, rt.calls)
+		}
+	}
+}
+
+func TestRetryAfterBeyondDeadline(t *testing.T) {
+	calls := 0
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Retry-After", "120")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	s := NewService("key", WithRetry(DefaultRetryPolicy))
+	s.BaseURL = srv.URL
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	_, err := s.GetContext(ctx, 37.8267, -122.4233)
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.RetryAfter != 120*time.Second {
+		t.Errorf("expected the 429 with its Retry-After got %v", err)
+	}
+
+	if calls != 1 {
+		t.Errorf("expected a single attempt got %d", calls)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	p := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, 100 * time.Millisecond},
+		{2, 200 * time.Millisecond},
+		{4, 800 * time.Millisecond},
+		{5, time.Second},
+		{50, time.Second},
+	}
+
+	for _, test := range tests {
+		if got := p.delay(test.attempt, errors.New("reset")); got != test.want {
+			t.Errorf("attempt %d expected %v got %v", test.attempt, test.want, got)
+		}
+	}
+
+	if got := p.delay(1, &APIError{RetryAfter: 500 * time.Millisecond}); got != 500*time.Millisecond {
+		t.Errorf("expected the server's Retry-After got %v", got)
+	}
+
+	if got := p.delay(1, &APIError{RetryAfter: 3 * time.Hour}); got != time.Second {
+		t.Errorf("expected Retry-After capped at MaxDelay got %v", got)
+	}
+
+	p.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if got := p.delay(2, errors.New("reset")); got < 100*time.Millisecond || got > 200*time.Millisecond {
+			t.Fatalf("jittered delay %v out of range", got)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package darksky
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var (
+	timeMachineJSON = []byte(`
+	{"latitude":37.8267,"longitude":-122.4233,"timezone":"America/Los_Angeles","currently":{"time":1551600000,"summary":"Partly Cloudy","icon":"partly-cloudy-night","precipIntensity":0,"precipProbability":0,"temperature":47.19,"apparentTemperature":44.82,"dewPoint":41.64,"humidity":0.81,"pressure":1019.4,"windSpeed":5.09,"windGust":8.21,"windBearing":283,"cloudCover":0.36,"uvIndex":0,"visibility":10,"ozone":338.6},"hourly":{"summary":"Partly cloudy throughout the day.","icon":"partly-cloudy-day","data":[{"time":1551600000,"summary":"Partly Cloudy","icon":"partly-cloudy-night","precipIntensity":0,"precipProbability":0,"temperature":47.19,"apparentTemperature":44.82,"dewPoint":41.64,"humidity":0.81,"pressure":1019.4,"windSpeed":5.09,"windGust":8.21,"windBearing":283,"cloudCover":0.36,"uvIndex":0,"visibility":10,"ozone":338.6},{"time":1551603600,"summary":"Clear","icon":"clear-night","precipIntensity":0,"precipProbability":0,"temperature":46.51,"apparentTemperature":44.01,"dewPoint":41.32,"humidity":0.82,"pressure":1019.72,"windSpeed":5.32,"windGust":7.95,"windBearing":290,"cloudCover":0.12,"uvIndex":0,"visibility":10,"ozone":339.11}]},"daily":{"data":[{"time":1551600000,"summary":"Partly cloudy throughout the day.","icon":"partly-cloudy-day","sunriseTime":1551623847,"sunsetTime":1551665046,"moonPhase":0.9,"precipIntensity":0.0003,"precipIntensityMax":0.0021,"precipIntensityMaxTime":1551679200,"precipProbability":0.05,"precipType":"rain","temperatureHigh":57.72,"temperatureHighTime":1551654000,"temperatureLow":46.12,"temperatureLowTime":1551704400,"apparentTemperatureHigh":57.72,"apparentTemperatureHighTime":1551654000,"apparentTemperatureLow":43.3,"apparentTemperatureLowTime":1551704400,"dewPoint":41.96,"humidity":0.72,"pressure":1019.83,"windSpeed":4.87,"windGust":13.92,"windGustTime":1551664800,"windBearing":276,"cloudCover":0.32,"uvIndex":4,"uvIndexTime":1551643200,"visibility":10,"ozone":334.17,"temperatureMin":44.9,"temperatureMinTime":1551621600,"temperatureMax":57.72,"temperatureMaxTime":1551654000,"apparentTemperatureMin":42.35,"apparentTemperatureMinTime":1551621600,"apparentTemperatureMax":57.72,"apparentTemperatureMaxTime":1551654000}]},"flags":{"sources":["cmc","gfs","hrrr","icon","isd","madis","nam","sref","darksky"],"nearest-station":1.839,"units":"us"},"offset":-8}
+	`)
+)
+
+func TestDarkskyGetAt(t *testing.T) {
+	var path, query string
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, query = r.URL.Path, r.URL.RawQuery
+		w.Write(timeMachineJSON)
+	}))
+	defer srv.Close()
+
+	s := NewService("key")
+	s.BaseURL = srv.URL
+
+	at := time.Unix(1551600000, 0)
+
+	res, err := s.GetAt(context.Background(), 37.8267, -122.4233, at)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if path != "/key/37.8267,-122.4233,1551600000" {
+		t.Errorf("unexpected path %s", path)
+	}
+
+	if query != "exclude=minutely&units=us" {
+		t.Errorf("unexpected query %s", query)
+	}
+
+	if res.Currently == nil || !res.Currently.Time.Equal(UnixTime(at)) {
+		t.Errorf("expected currently at %v got %v", at, res.Currently)
+	}
+
+	if res.Daily == nil || len(res.Daily.Data) != 1 {
+		t.Errorf("expected a single day of daily data")
+	}
+
+	if res.Offset != -8 {
+		t.Errorf("expected offset -8 got %v", res.Offset)
+	}
+}
+
+func TestDarkskyGetAtISO8601(t *testing.T) {
+	var path string
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		w.Write(timeMachineJSON)
+	}))
+	defer srv.Close()
+
+	s := NewService("key")
+	s.BaseURL = srv.URL
+
+	tests := []struct {
+		at   time.Time
+		want string
+	}{
+		{time.Unix(1551600000, 0).UTC(), "/key/37.8267,-122.4233,2019-03-03T08:00:00Z"},
+		{time.Unix(1551600000, 0).In(time.FixedZone("PST", -8*3600)), "/key/37.8267,-122.4233,2019-03-03T00:00:00-0800"},
+		{time.Unix(1551600000, 0).In(time.FixedZone("IST", 5*3600+1800)), "/key/37.8267,-122.4233,2019-03-03T13:30:00+0530"},
+	}
+
+	for _, test := range tests {
+		if _, err := s.GetAt(context.Background(), 37.8267, -122.4233, test.at, WithTimeFormat(TimeISO8601)); err != nil {
+			t.Fatal(err)
+		}
+
+		if path != test.want {
+			t.Errorf("expected path %s got %s", test.want, path)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package darksky
+
+import (
+	"fmt"
+)
+
+/*
+quantity is a kind of measurement whose unit depends on the unit system
+*/
+type quantity int
+
+const (
+	temperature quantity = iota
+	speed
+	distance
+	precipIntensity
+	precipAccumulation
+)
+
+/*
+unit is a concrete unit of measurement
+*/
+type unit int
+
+const (
+	fahrenheit unit = iota
+	celsius
+	milesPerHour
+	metersPerSecond
+	kilometersPerHour
+	miles
+	kilometers
+	inchesPerHour
+	millimetersPerHour
+	inches
+	centimeters
+)
+
+/*
+unitOf returns the unit u reports q in. Pressure is left out because millibars
+and hectopascals are the same unit.
+*/
+func (u Units) unitOf(q quantity) (unit, bool) {
+	if !u.valid() || u == UnitsAuto {
+		return 0, false
+	}
+
+	switch q {
+	case temperature:
+		if u == UnitsUS {
+			return fahrenheit, true
+		}
+		return celsius, true
+	case speed:
+		switch u {
+		case UnitsUS, UnitsUK2:
+			return milesPerHour, true
+		case UnitsCA:
+			return kilometersPerHour, true
+		}
+		return metersPerSecond, true
+	case distance:
+		if u == UnitsUS || u == UnitsUK2 {
+			return miles, true
+		}
+		return kilometers, true
+	case precipIntensity:
+		if u == UnitsUS {
+			return inchesPerHour, true
+		}
+		return millimetersPerHour, true
+	case precipAccumulation:
+		if u == UnitsUS {
+			return inches, true
+		}
+		return centimeters, true
+	}
+
+	return 0, false
+}
+
+/*
+toBase and fromBase convert between a unit and the metric unit of its
+quantity: celsius, meters per second, kilometers, millimeters per hour and
+centimeters
+*/
+func (n unit) toBase(v float64) float64 {
+	switch n {
+	case fahrenheit:
+		return (v - 32) * 5 / 9
+	case milesPerHour:
+		return v * 0.44704
+	case kilometersPerHour:
+		return v / 3.6
+	case miles:
+		return v * 1.609344
+	case inchesPerHour:
+		return v * 25.4
+	case inches:
+		return v * 2.54
+	}
+	return v
+}
+
+func (n unit) fromBase(v float64) float64 {
+	switch n {
+	case fahrenheit:
+		return v*9/5 + 32
+	case milesPerHour:
+		return v / 0.44704
+	case kilometersPerHour:
+		return v * 3.6
+	case miles:
+		return v / 1.609344
+	case inchesPerHour:
+		return v / 25.4
+	case inches:
+		return v / 2.54
+	}
+	return v
+}
+
+/*
+converter rewrites values of each quantity from one unit system to another
+*/
+type converter struct {
+	from, to [precipAccumulation + 1]unit
+	// done holds the values already converted, as providers may share one
+	// between fields such as Currently and the first hour
+	done map[*float32]bool
+}
+
+func newConverter(from, to Units) (*converter, error) {
+	c := &converter{done: make(map[*float32]bool)}
+
+	for q := temperature; q <= precipAccumulation; q++ {
+		var ok bool
+		if c.from[q], ok = from.unitOf(q); !ok {
+			return nil, fmt.Errorf("darksky: cannot convert from units %q", from)
+		}
+		if c.to[q], ok = to.unitOf(q); !ok {
+			return nil, fmt.Errorf("darksky: cannot convert to units %q", to)
+		}
+	}
+
+	return c, nil
+}
+
+func (c *converter) value(q quantity, v float32) float32 {
+	if c.from[q] == c.to[q] {
+		return v
+	}
+	return float32(c.to[q].fromBase(c.from[q].toBase(float64(v))))
+}
+
+func (c *converter) convertPtr(q quantity, v *float32) {
+	if v != nil && !c.done[v] {
+		*v = c.value(q, *v)
+		c.done[v] = true
+	}
+}
+
+/*
+convertData rewrites every unit-dependent field of d
+*/
+func (c *converter) convertData(d *Data) {
+	for _, t := range []*float32{
+		d.Temperature,
+		d.ApparentTemperature,
+		d.TemperatureLow,
+		d.TemperatureHigh,
+		d.ApparentTemperatureLow,
+		d.ApparentTemperatureHigh,
+		d.TemperatureMin,
+		d.TemperatureMax,
+		d.ApparentTemperatureMin,
+		d.ApparentTemperatureMax,
+		d.DewPoint,
+		d.FeelsLike,
+	} {
+		c.convertPtr(temperature, t)
+	}
+
+	c.convertPtr(speed, d.WindSpeed)
+	c.convertPtr(speed, d.WindGust)
+
+	c.convertPtr(distance, d.NearestStormDistance)
+	c.convertPtr(distance, d.Visibility)
+
+	c.convertPtr(precipIntensity, d.PrecipIntensity)
+	c.convertPtr(precipIntensity, d.PrecipIntensityError)
+	c.convertPtr(precipIntensity, d.PrecipIntensityMax)
+
+	c.convertPtr(precipAccumulation, d.PrecipAccumulation)
+	c.convertPtr(precipAccumulation, d.LiquidAccumulation)
+	c.convertPtr(precipAccumulation, d.SnowAccumulation)
+	c.convertPtr(precipAccumulation, d.IceAccumulation)
+}
+
+/*
+ConvertTo rewrites every temperature, speed, distance and precipitation value
+in the response, including Flags.NearestStation, from the units in
+r.Flags.Units to u, and records u in r.Flags.Units. Pointer fields are updated
+in place, so the response must not share Data with another response; a value
+shared within it is converted once.
+*/
+func (r *Response) ConvertTo(u Units) error {
+	c, err := newConverter(r.Flags.Units, u)
+	if err != nil {
+		return err
+	}
+
+	if r.Currently != nil {
+		c.convertData(r.Currently)
+	}
+
+	for _, block := range []*DataSummary{r.Minutely, r.Hourly, r.Daily} {
+		if block == nil {
+			continue
+		}
+		for i := range block.Data {
+			c.convertData(&block.Data[i])
+		}
+	}
+
+	c.convertPtr(distance, r.Flags.NearestStation)
+
+	r.Flags.Units = u
+	return nil
+}
@@ -0,0 +1,126 @@
+package darksky
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func approx(a, b float32) bool {
+	return math.Abs(float64(a-b)) <= 1e-3*math.Max(1, math.Abs(float64(b)))
+}
+
+func TestConvertTo(t *testing.T) {
+	type values struct {
+		temperature, dewPoint, windSpeed, visibility, stormDistance, precipIntensity, pressure, station float32
+	}
+
+	us := values{50, 41, 10, 6.2137119, 3.1068560, 0.1, 1003.16, 10}
+
+	tests := []struct {
+		units Units
+		want  values
+	}{
+		{UnitsUS, us},
+		{UnitsSI, values{10, 5, 4.4704, 10, 5, 2.54, 1003.16, 16.09344}},
+		{UnitsCA, values{10, 5, 16.09344, 10, 5, 2.54, 1003.16, 16.09344}},
+		{UnitsUK2, values{10, 5, 10, 6.2137119, 3.1068560, 2.54, 1003.16, 10}},
+	}
+
+	for _, from := range tests {
+		for _, to := range tests {
+			temp, dew, storm := from.want.temperature, from.want.dewPoint, from.want.stormDistance
+
+			res := Response{
+				Currently: &Data{
+					Temperature:          &temp,
+					DewPoint:             &dew,
+					WindSpeed:            Float32(from.want.windSpeed),
+					Visibility:           Float32(from.want.visibility),
+					NearestStormDistance: &storm,
+					PrecipIntensity:      Float32(from.want.precipIntensity),
+					Pressure:             Float32(from.want.pressure),
+				},
+				Flags: Flags{Units: from.units, NearestStation: Float32(from.want.station)},
+			}
+
+			if err := res.ConvertTo(to.units); err != nil {
+				t.Fatal(err)
+			}
+
+			d := res.Currently
+			got := values{*d.Temperature, *d.DewPoint, *d.WindSpeed, *d.Visibility, *d.NearestStormDistance, *d.PrecipIntensity, *d.Pressure, *res.Flags.NearestStation}
+
+			if !approx(got.temperature, to.want.temperature) ||
+				!approx(got.dewPoint, to.want.dewPoint) ||
+				!approx(got.windSpeed, to.want.windSpeed) ||
+				!approx(got.visibility, to.want.visibility) ||
+				!approx(got.stormDistance, to.want.stormDistance) ||
+				!approx(got.precipIntensity, to.want.precipIntensity) ||
+				!approx(got.pressure, to.want.pressure) ||
+				!approx(got.station, to.want.station) {
+				t.Errorf("%s to %s expected %+v got %+v", from.units, to.units, to.want, got)
+			}
+
+			if res.Flags.Units != to.units {
+				t.Errorf("expected flags units %s got %s", to.units, res.Flags.Units)
+			}
+		}
+	}
+}
+
+func TestConvertToFixture(t *testing.T) {
+	var res Response
+
+	if err := json.Unmarshal(exampleJSON, &res); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := res.ConvertTo(UnitsSI); err != nil {
+		t.Fatal(err)
+	}
+
+	if !approx(*res.Currently.Temperature, 12.8278) {
+		t.Errorf("expected current temperature %f got %f", 12.8278, *res.Currently.Temperature)
+	}
+
+	if !approx(*res.Hourly.Data[0].Temperature, 12.7333) {
+		t.Errorf("expected first hourly temperature %f got %f", 12.7333, *res.Hourly.Data[0].Temperature)
+	}
+
+	if !approx(*res.Daily.Data[0].TemperatureHigh, 12.8889) {
+		t.Errorf("expected first daily high %f got %f", 12.8889, *res.Daily.Data[0].TemperatureHigh)
+	}
+}
+
+func TestConvertToInvalid(t *testing.T) {
+	res := Response{Flags: Flags{Units: UnitsUS}}
+
+	if err := res.ConvertTo(UnitsAuto); err == nil {
+		t.Errorf("expected an error converting to auto")
+	}
+
+	res.Flags.Units = ""
+	if err := res.ConvertTo(UnitsSI); err == nil {
+		t.Errorf("expected an error converting from unknown units")
+	}
+}
+
+func TestConvertToShared(t *testing.T) {
+	high := Float32(20)
+	current := Data{Temperature: high}
+
+	res := Response{
+		Flags:     Flags{Units: UnitsSI},
+		Currently: &current,
+		Daily:     &DataSummary{Data: []Data{{TemperatureHigh: high, TemperatureMax: high}}},
+	}
+
+	if err := res.ConvertTo(UnitsUS); err != nil {
+		t.Fatal(err)
+	}
+
+	if *high != 68 {
+		t.Errorf("expected a shared value to be converted once got %v", *high)
+	}
+}
@@ -0,0 +1,335 @@
+package darksky
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+UnixTime is a time encoded in JSON as UNIX seconds. Times in a Response returned
+by a Service are in the forecast location's zone; see Response.Localize.
+
+Decoding accepts integer and fractional seconds, either bare or quoted, and
+null, which leaves the zero UnixTime. The zero UnixTime encodes as null.
+*/
+type UnixTime time.Time
+
+// maxUnixSeconds bounds decoded times well inside what time.Time represents
+const maxUnixSeconds = 1e15
+
+func (u *UnixTime) UnmarshalJSON(b []byte) error {
+	b = bytes.TrimSpace(b)
+
+	if string(b) == "null" {
+		*u = UnixTime{}
+		return nil
+	}
+
+	if len(b) > 0 && b[0] == '"' {
+		var s string
+		if err := json.Unmarshal(b, &s); err != nil {
+			return err
+		}
+		return u.UnmarshalText([]byte(s))
+	}
+
+	t, err := parseUnix(string(b))
+	if err != nil {
+		return err
+	}
+
+	*u = UnixTime(t)
+	return nil
+}
+
+func (u UnixTime) MarshalJSON() ([]byte, error) {
+	if u.IsZero() {
+		return []byte("null"), nil
+	}
+	return []byte(formatUnix(time.Time(u))), nil
+}
+
+/*
+UnmarshalText implements encoding.TextUnmarshaler, reading UNIX seconds. Empty
+text leaves the zero UnixTime.
+*/
+func (u *UnixTime) UnmarshalText(b []byte) error {
+	s := strings.TrimSpace(string(b))
+
+	if s == "" {
+		*u = UnixTime{}
+		return nil
+	}
+
+	t, err := parseUnix(s)
+	if err != nil {
+		return err
+	}
+
+	*u = UnixTime(t)
+	return nil
+}
+
+/*
+MarshalText implements encoding.TextMarshaler, writing UNIX seconds, or
+nothing for the zero UnixTime
+*/
+func (u UnixTime) MarshalText() ([]byte, error) {
+	if u.IsZero() {
+		return []byte{}, nil
+	}
+	return []byte(formatUnix(time.Time(u))), nil
+}
+
+/*
+Scan implements sql.Scanner for time, integer, float and textual columns
+*/
+func (u *UnixTime) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*u = UnixTime{}
+	case time.Time:
+		*u = UnixTime(v)
+	case int64:
+		*u = UnixTime(time.Unix(v, 0))
+	case float64:
+		t, err := parseUnix(strconv.FormatFloat(v, 'f', -1, 64))
+		if err != nil {
+			return err
+		}
+		*u = UnixTime(t)
+	case []byte:
+		return u.UnmarshalText(v)
+	case string:
+		return u.UnmarshalText([]byte(v))
+	default:
+		return fmt.Errorf("darksky: cannot scan %T into UnixTime", src)
+	}
+	return nil
+}
+
+/*
+Value implements driver.Valuer, storing the zero UnixTime as NULL
+*/
+func (u UnixTime) Value() (driver.Value, error) {
+	if u.IsZero() {
+		return nil, nil
+	}
+	return time.Time(u), nil
+}
+
+/*
+IsZero reports whether u is the zero UnixTime
+*/
+func (u UnixTime) IsZero() bool {
+	return time.Time(u).IsZero()
+}
+
+/*
+parseUnix reads seconds since the epoch, keeping nanosecond precision for
+plain decimals and falling back to float parsing for exponents
+*/
+func parseUnix(s string) (time.Time, error) {
+	if sec, err := strconv.ParseInt(s, 10, 64); err == nil {
+		if sec > maxUnixSeconds || sec < -maxUnixSeconds {
+			return time.Time{}, fmt.Errorf("darksky: unix time %q out of range", s)
+		}
+		return time.Unix(sec, 0), nil
+	}
+
+	if i := strings.IndexByte(s, '.'); i > 0 && !strings.ContainsAny(s, "eE") {
+		whole, frac := s[:i], s[i+1:]
+
+		sec, err := strconv.ParseInt(whole, 10, 64)
+		if err == nil && whole != "-" && whole != "+" && len(frac) > 0 && strings.Trim(frac, "0123456789") == "" {
+			if sec > maxUnixSeconds || sec < -maxUnixSeconds {
+				return time.Time{}, fmt.Errorf("darksky: unix time %q out of range", s)
+			}
+
+			if len(frac) > 9 {
+				frac = frac[:9]
+			}
+			nsec, _ := strconv.ParseInt(frac+strings.Repeat("0", 9-len(frac)), 10, 64)
+
+			if strings.HasPrefix(whole, "-") {
+				nsec = -nsec
+			}
+			return time.Unix(sec, nsec), nil
+		}
+	}
+
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("darksky: invalid unix time %q", s)
+	}
+
+	if math.IsNaN(f) || f > maxUnixSeconds || f < -maxUnixSeconds {
+		return time.Time{}, fmt.Errorf("darksky: unix time %q out of range", s)
+	}
+
+	sec, frac := math.Modf(f)
+	return time.Unix(int64(sec), int64(math.Round(frac*1e9))), nil
+}
+
+/*
+formatUnix writes t as seconds since the epoch, with a fraction only when t
+is not on a whole second
+*/
+func formatUnix(t time.Time) string {
+	sec, nsec := t.Unix(), int64(t.Nanosecond())
+	if nsec == 0 {
+		return strconv.FormatInt(sec, 10)
+	}
+
+	sign := ""
+	if sec < 0 {
+		sign, sec, nsec = "-", -sec-1, 1e9-nsec
+	}
+
+	return sign + strconv.FormatInt(sec, 10) + "." + strings.TrimRight(fmt.Sprintf("%09d", nsec), "0")
+}
+
+/*
+Time returns u as a time.Time
+*/
+func (u UnixTime) Time() time.Time {
+	return time.Time(u)
+}
+
+/*
+String formats u like time.Time
+*/
+func (u UnixTime) String() string {
+	return time.Time(u).String()
+}
+
+/*
+Equal reports whether u and v are the same instant, whatever their zones
+*/
+func (u UnixTime) Equal(v UnixTime) bool {
+	return time.Time(u).Equal(time.Time(v))
+}
+
+/*
+In returns u in loc
+*/
+func (u UnixTime) In(loc *time.Location) UnixTime {
+	return UnixTime(time.Time(u).In(loc))
+}
+
+/*
+Location returns the zone of the forecast location: the IANA zone named by
+Timezone if it can be loaded, otherwise a fixed zone Offset hours from UTC
+*/
+func (r Response) Location() *time.Location {
+	if r.Timezone != "" {
+		if loc, err := loadLocation(r.Timezone); err == nil {
+			return loc
+		}
+	}
+
+	return time.FixedZone(r.Timezone, int(r.Offset*3600))
+}
+
+/*
+locations caches loaded zones, as time.LoadLocation reads the zone database on
+every call
+*/
+var locations sync.Map
+
+type locationResult struct {
+	loc *time.Location
+	err error
+}
+
+func loadLocation(name string) (*time.Location, error) {
+	if v, ok := locations.Load(name); ok {
+		r := v.(locationResult)
+		return r.loc, r.err
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err == nil {
+		// only successes are cached so arbitrary names cannot grow the map
+		locations.Store(name, locationResult{loc, err})
+	}
+	return loc, err
+}
+
+/*
+Localize moves every time in the response into r.Location(). Service and
+CachedService do it for every Response they return, so it only needs calling
+on a Response decoded or built by hand, or after changing Timezone or Offset.
+*/
+func (r *Response) Localize() {
+	loc := r.Location()
+
+	for _, t := range r.times() {
+		*t = t.In(loc)
+	}
+}
+
+/*
+times returns pointers to every time in the response
+*/
+func (r *Response) times() []*UnixTime {
+	var ret []*UnixTime
+
+	if r.Currently != nil {
+		ret = r.Currently.times(ret)
+	}
+
+	for _, block := range []*DataSummary{r.Minutely, r.Hourly, r.Daily} {
+		if block == nil {
+			continue
+		}
+		for i := range block.Data {
+			ret = block.Data[i].times(ret)
+		}
+	}
+
+	for i := range r.Alerts {
+		ret = append(ret, &r.Alerts[i].Time)
+		if r.Alerts[i].Expires != nil {
+			ret = append(ret, r.Alerts[i].Expires)
+		}
+	}
+
+	return ret
+}
+
+/*
+times appends pointers to every time in d to ret
+*/
+func (d *Data) times(ret []*UnixTime) []*UnixTime {
+	ret = append(ret, &d.Time)
+
+	for _, t := range []*UnixTime{
+		d.SunriseTime,
+		d.SunsetTime,
+		d.PrecipIntensityMaxTime,
+		d.TemperatureHighTime,
+		d.TemperatureLowTime,
+		d.ApparentTemperatureHighTime,
+		d.ApparentTemperatureLowTime,
+		d.WindGustTime,
+		d.UVIndexTime,
+		d.TemperatureMinTime,
+		d.TemperatureMaxTime,
+		d.ApparentTemperatureMinTime,
+		d.ApparentTemperatureMaxTime,
+	} {
+		if t != nil {
+			ret = append(ret, t)
+		}
+	}
+
+	return ret
+}
@@ -0,0 +1,225 @@
+package darksky
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestResponseLocalize(t *testing.T) {
+	var res Response
+
+	if err := json.Unmarshal(dailyJSON, &res); err != nil {
+		t.Fatal(err)
+	}
+
+	res.Localize()
+
+	// 1551855600 is midnight in Denver but still the previous day in UTC
+	day := res.Daily.Data[0].Time.Time()
+
+	if y, m, d := day.Date(); y != 2019 || m != time.March || d != 6 || day.Hour() != 0 {
+		t.Errorf("expected midnight on 2019-03-06 got %v", day)
+	}
+
+	if _, offset := day.Zone(); offset != -7*3600 {
+		t.Errorf("expected offset -7h got %ds", offset)
+	}
+
+	sunset := res.Daily.Data[0].SunsetTime.Time()
+	if sunset.Location() != day.Location() {
+		t.Errorf("expected every time to share the location zone")
+	}
+}
+
+func TestResponseLocalizeFallback(t *testing.T) {
+	res := Response{
+		Timezone:  "Asia/Nowhere",
+		Offset:    5.5,
+		Currently: &Data{Time: UnixTime(time.Unix(1551886726, 0).UTC())},
+	}
+
+	res.Localize()
+
+	got := res.Currently.Time.Time()
+
+	if _, offset := got.Zone(); offset != 5*3600+1800 {
+		t.Errorf("expected offset 5h30m got %ds", offset)
+	}
+
+	if got.Hour() != 21 || got.Minute() != 8 {
+		t.Errorf("expected 21:08 got %v", got)
+	}
+
+	if !res.Currently.Time.Equal(UnixTime(time.Unix(1551886726, 0))) {
+		t.Errorf("localizing should not change the instant")
+	}
+}
+
+func TestUnixTimeString(t *testing.T) {
+	u := UnixTime(time.Unix(1551886726, 0).In(time.FixedZone("PST", -8*3600)))
+
+	if got := u.String(); got != "2019-03-06 07:38:46 -0800 PST" {
+		t.Errorf("unexpected string %s", got)
+	}
+}
+
+func TestUnixTimeUnmarshalTolerant(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Time
+	}{
+		{`1551886726`, time.Unix(1551886726, 0)},
+		{`1551886726.25`, time.Unix(1551886726, 250000000)},
+		{`1551886726.123456789123`, time.Unix(1551886726, 123456789)},
+		{`1.551886726e9`, time.Unix(1551886726, 0)},
+		{`"1551886726"`, time.Unix(1551886726, 0)},
+		{`" 1551886726.5 "`, time.Unix(1551886726, 500000000)},
+		{`-1.5`, time.Unix(-2, 500000000)},
+		{`null`, time.Time{}},
+		{`""`, time.Time{}},
+	}
+
+	for _, test := range tests {
+		u := UnixTime(time.Unix(1, 0))
+		if err := json.Unmarshal([]byte(test.in), &u); err != nil {
+			t.Errorf("%s: %v", test.in, err)
+		} else if !u.Equal(UnixTime(test.want)) {
+			t.Errorf("%s: expected %v got %v", test.in, test.want, u)
+		}
+	}
+
+	for _, in := range []string{`true`, `{}`, `"soon"`, `"NaN"`, `1e300`, `"-Inf"`, `[1]`} {
+		var u UnixTime
+		if err := json.Unmarshal([]byte(in), &u); err == nil {
+			t.Errorf("%s: expected an error got %v", in, u)
+		}
+	}
+}
+
+func TestUnixTimeMarshal(t *testing.T) {
+	tests := []struct {
+		in   UnixTime
+		json string
+	}{
+		{UnixTime(time.Unix(1551886726, 0)), `1551886726`},
+		{UnixTime(time.Unix(1551886726, 250000000)), `1551886726.25`},
+		{UnixTime(time.Unix(-2, 500000000)), `-1.5`},
+		{UnixTime{}, `null`},
+	}
+
+	for _, test := range tests {
+		b, err := json.Marshal(test.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(b) != test.json {
+			t.Errorf("expected %s got %s", test.json, b)
+		}
+
+		var back UnixTime
+		if err := json.Unmarshal(b, &back); err != nil || !back.Equal(test.in) {
+			t.Errorf("%s did not round trip: %v %v", b, back, err)
+		}
+	}
+}
+
+func TestUnixTimeText(t *testing.T) {
+	m := map[UnixTime]string{UnixTime(time.Unix(1551886726, 0).UTC()): "now"}
+
+	b, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(b) != `{"1551886726":"now"}` {
+		t.Errorf("unexpected text key encoding %s", b)
+	}
+
+	var back map[UnixTime]string
+	if err := json.Unmarshal(b, &back); err != nil {
+		t.Fatal(err)
+	}
+
+	for k, v := range back {
+		if !k.Equal(UnixTime(time.Unix(1551886726, 0))) || v != "now" {
+			t.Errorf("unexpected entry %v %s", k, v)
+		}
+	}
+}
+
+func TestUnixTimeSQL(t *testing.T) {
+	want := UnixTime(time.Unix(1551886726, 500000000))
+
+	for _, src := range []interface{}{time.Unix(1551886726, 500000000), float64(1551886726.5), []byte("1551886726.5"), "1551886726.5"} {
+		var u UnixTime
+		if err := u.Scan(src); err != nil {
+			t.Errorf("%T: %v", src, err)
+		} else if !u.Equal(want) {
+			t.Errorf("%T: expected %v got %v", src, want, u)
+		}
+	}
+
+	var u UnixTime
+	if err := u.Scan(int64(1551886726)); err != nil || !u.Equal(UnixTime(time.Unix(1551886726, 0))) {
+		t.Errorf("int64: unexpected %v %v", u, err)
+	}
+
+	if err := u.Scan(nil); err != nil || !u.IsZero() {
+		t.Errorf("nil: unexpected %v %v", u, err)
+	}
+
+	if err := u.Scan(true); err == nil {
+		t.Errorf("expected an error scanning a bool")
+	}
+
+	if v, err := want.Value(); err != nil || !v.(time.Time).Equal(want.Time()) {
+		t.Errorf("unexpected value %v %v", v, err)
+	}
+
+	if v, err := (UnixTime{}).Value(); err != nil || v != nil {
+		t.Errorf("expected NULL for the zero time got %v %v", v, err)
+	}
+}
+
+func FuzzUnixTimeUnmarshalJSON(f *testing.F) {
+	for _, seed := range []string{`1551886726`, `1551886726.25`, `"1551886726"`, `null`, `-1.5`, `1.551886726e9`, `"NaN"`, `1e300`, `""`} {
+		f.Add([]byte(seed))
+	}
+
+	f.Fuzz(func(t *testing.T, b []byte) {
+		var u UnixTime
+		if err := u.UnmarshalJSON(b); err != nil {
+			return
+		}
+
+		out, err := u.MarshalJSON()
+		if err != nil {
+			t.Fatalf("%q decoded but did not encode: %v", b, err)
+		}
+
+		var back UnixTime
+		if err := back.UnmarshalJSON(out); err != nil || !back.Equal(u) {
+			t.Fatalf("%q encoded as %s which decodes to %v (%v), not %v", b, out, back, err, u)
+		}
+	})
+}
+
+func FuzzResponseUnmarshal(f *testing.F) {
+	f.Add(exampleJSON)
+	f.Add(dailyJSON)
+	f.Add(alertsJSON)
+	f.Add([]byte(`{"currently":{"time":"1551886726.5"},"offset":5.5,"timezone":"Asia/Kolkata"}`))
+
+	f.Fuzz(func(t *testing.T, b []byte) {
+		var res Response
+		if err := json.Unmarshal(b, &res); err != nil {
+			return
+		}
+
+		if _, err := json.Marshal(res); err != nil {
+			t.Fatalf("%q decoded but did not encode: %v", b, err)
+		}
+	})
+}